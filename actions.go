@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/paked/messenger"
+)
+
+// Quick reply actions of failure notifications.
+const (
+	ActionRebuild = "rebuild"
+	ActionAck     = "ack"
+	ActionMute    = "mute"
+)
+
+// Confirmation actions of the restart and promote commands.
+const (
+	ActionRestart = "restart"
+	ActionPromote = "promote"
+	ActionCancel  = "cancel"
+)
+
+const (
+	actionPrefix = "drone"
+	muteDuration = time.Hour
+)
+
+// Action is a quick reply payload, it names the action and the build it
+// applies to, and the deploy target of promotions.
+type Action struct {
+	Name   string
+	Repo   string
+	Build  int
+	Target string
+}
+
+// Payload encodes the action as drone:<name>:<repo>:<build>[:<target>].
+func (a Action) Payload() string {
+	payload := fmt.Sprintf("%s:%s:%s:%d", actionPrefix, a.Name, a.Repo, a.Build)
+	if a.Target != "" {
+		payload += ":" + a.Target
+	}
+
+	return payload
+}
+
+// parseAction decodes a quick reply payload built by Action.Payload.
+func parseAction(payload string) (Action, bool) {
+	fields := strings.Split(payload, ":")
+	if len(fields) < 4 || len(fields) > 5 || fields[0] != actionPrefix {
+		return Action{}, false
+	}
+
+	build, err := strconv.Atoi(fields[3])
+	if err != nil {
+		return Action{}, false
+	}
+
+	action := Action{Name: fields[1], Repo: fields[2], Build: build}
+	if len(fields) == 5 {
+		action.Target = fields[4]
+	}
+
+	return action, true
+}
+
+// quickReplyAction returns the action of a tapped quick reply.
+func quickReplyAction(m messenger.Message) (Action, bool) {
+	if m.QuickReply == nil {
+		return Action{}, false
+	}
+
+	return parseAction(m.QuickReply.Payload)
+}
+
+// quickReplies returns the quick replies attached to failure notifications.
+func (p Plugin) quickReplies() []messenger.QuickReply {
+	if !p.Config.QuickReplies {
+		return nil
+	}
+
+	switch p.Build.Status {
+	case "failure", "error":
+	default:
+		return nil
+	}
+
+	replies := []struct {
+		title string
+		name  string
+	}{
+		{"Rebuild", ActionRebuild},
+		{"Ack", ActionAck},
+		{"Mute 1h", ActionMute},
+	}
+
+	var out []messenger.QuickReply
+	for _, reply := range replies {
+		// the webhook server restarts builds on the drone server, don't
+		// offer it when the step doesn't name one.
+		if reply.name == ActionRebuild && p.Config.DroneServer == "" {
+			continue
+		}
+
+		action := Action{Name: reply.name, Repo: p.Repo.FullName, Build: p.Build.Number}
+		out = append(out, messenger.QuickReply{
+			ContentType: "text",
+			Title:       reply.title,
+			Payload:     action.Payload(),
+		})
+	}
+
+	return out
+}
+
+// handleAction runs the action of a tapped quick reply and returns the reply text.
+func (p Plugin) handleAction(ctx *Context, action Action) string {
+	switch action.Name {
+	case ActionAck:
+		log.Printf("%s acknowledged build #%d of %s\n", ctx.Name, action.Build, action.Repo)
+		return fmt.Sprintf("%s acknowledged build #%d of %s.", ctx.Name, action.Build, action.Repo)
+	case ActionMute:
+		if ctx.Store == nil {
+			return "Mute is not available, the webhook server has no store configured."
+		}
+		if err := ctx.Store.Mute(ctx.User, action.Repo, time.Now().Add(muteDuration)); err != nil {
+			log.Println("error to save the mute:", err)
+			return "Something went wrong, try again later."
+		}
+		return fmt.Sprintf("Muted %s for 1 hour.", action.Repo)
+	case ActionRebuild:
+		action.Name = ActionRestart
+		return p.confirm(ctx, action)
+	case ActionRestart, ActionPromote, ActionCancel:
+		return p.runConfirmed(ctx, action)
+	}
+
+	return fmt.Sprintf("Unknown action: %s", action.Name)
+}
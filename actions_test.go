@@ -0,0 +1,105 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestActionPayload(t *testing.T) {
+	action := Action{Name: ActionMute, Repo: "appleboy/go-hello", Build: 101}
+	assert.Equal(t, "drone:mute:appleboy/go-hello:101", action.Payload())
+
+	parsed, ok := parseAction(action.Payload())
+	assert.True(t, ok)
+	assert.Equal(t, action, parsed)
+
+	_, ok = parseAction("hello")
+	assert.False(t, ok)
+	_, ok = parseAction("drone:mute:appleboy/go-hello:abc")
+	assert.False(t, ok)
+}
+
+func TestQuickReplies(t *testing.T) {
+	plugin := Plugin{
+		Repo: Repo{
+			FullName: "appleboy/go-hello",
+		},
+		Build: Build{
+			Number: 101,
+			Status: "failure",
+		},
+		Config: Config{
+			To:           []string{"1"},
+			Message:      []string{"build failed"},
+			Image:        []string{"https://example.com/1.png"},
+			QuickReplies: true,
+		},
+	}
+
+	// no rebuild without a drone server to restart the build on
+	replies := plugin.quickReplies()
+	assert.Len(t, replies, 2)
+	assert.Equal(t, "Ack", replies[0].Title)
+
+	plugin.Config.DroneServer = "https://drone.example.com"
+	replies = plugin.quickReplies()
+	assert.Len(t, replies, 3)
+	assert.Equal(t, "Rebuild", replies[0].Title)
+	assert.Equal(t, "drone:rebuild:appleboy/go-hello:101", replies[0].Payload)
+
+	// attached to the last message only
+	extras, err := plugin.extras()
+	assert.NoError(t, err)
+	client := &recordSender{}
+	plugin.notify(client, 1, []string{"build failed"}, extras, &sendReport{})
+	assert.Len(t, client.requests, 2)
+	assert.Empty(t, client.requests[0].Message.QuickReplies)
+	assert.Equal(t, replies, client.requests[1].Message.QuickReplies)
+
+	plugin.Build.Status = "success"
+	assert.Empty(t, plugin.quickReplies())
+}
+
+type recordSender struct {
+	requests []*sendRequest
+}
+
+func (r *recordSender) Dispatch(req *sendRequest) error {
+	r.requests = append(r.requests, req)
+	return nil
+}
+
+func TestHandleAction(t *testing.T) {
+	dir, err := ioutil.TempDir("", "drone-facebook")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	plugin := Plugin{
+		Config: Config{
+			StorePath: filepath.Join(dir, "store.json"),
+		},
+	}
+	store, err := plugin.Store()
+	assert.NoError(t, err)
+
+	ctx := &Context{User: 1, Name: "Bo-Yi", Store: store}
+
+	action := Action{Name: ActionAck, Repo: "appleboy/go-hello", Build: 101}
+	assert.Equal(t, "Bo-Yi acknowledged build #101 of appleboy/go-hello.", plugin.handleAction(ctx, action))
+
+	action.Name = ActionMute
+	assert.Equal(t, "Muted appleboy/go-hello for 1 hour.", plugin.handleAction(ctx, action))
+	assert.Equal(t, "Mute is not available, the webhook server has no store configured.", plugin.handleAction(&Context{User: 1}, action))
+
+	// muted recipients are skipped by Exec
+	plugin.Repo.FullName = "appleboy/go-hello"
+	plugin.Config.To = []string{"1", "2"}
+	client := &fakeSender{}
+	assert.NoError(t, plugin.send(client, nil))
+	assert.Nil(t, client.sent[1])
+	assert.NotNil(t, client.sent[2])
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/paked/messenger"
+)
+
+// maxAttachmentSize is the Send API limit of uploaded files.
+const maxAttachmentSize = 25 << 20
+
+// isURL reports whether an attachment setting is a URL rather than a local path.
+func isURL(value string) bool {
+	return strings.HasPrefix(value, "http://") || strings.HasPrefix(value, "https://")
+}
+
+// attachments returns the image, audio, video and file parts. URLs are
+// sent as is, local paths and globs are resolved against the workspace
+// and uploaded. Missing files and files Messenger won't accept fail the
+// step.
+func (p Plugin) attachments() ([]part, error) {
+	var parts []part
+
+	lists := []struct {
+		kind     string
+		dataType messenger.AttachmentType
+		values   []string
+	}{
+		{"image", messenger.ImageAttachment, p.Config.Image},
+		{"audio", messenger.AudioAttachment, p.Config.Audio},
+		{"video", messenger.VideoAttachment, p.Config.Video},
+		{"file", messenger.FileAttachment, p.Config.File},
+	}
+
+	for _, list := range lists {
+		for _, value := range trimElement(list.values) {
+			if isURL(value) {
+				parts = append(parts, part{kind: list.kind, message: attachmentMessage(list.dataType, value)})
+				continue
+			}
+
+			files, err := p.localFiles(value)
+			if err != nil {
+				return nil, fmt.Errorf("can't find the %s: %v", list.kind, err)
+			}
+
+			for _, file := range files {
+				if err := checkAttachment(list.dataType, file); err != nil {
+					return nil, fmt.Errorf("can't upload the %s: %v", list.kind, err)
+				}
+				parts = append(parts, part{kind: list.kind, message: uploadMessage(list.dataType), file: file})
+			}
+		}
+	}
+
+	return parts, nil
+}
+
+// localFiles expands a path or glob relative to the workspace.
+func (p Plugin) localFiles(pattern string) ([]string, error) {
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(p.workspace(), pattern)
+	}
+
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no file matches %s", pattern)
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+// checkAttachment checks the file against the Messenger size limit and
+// the attachment type.
+func checkAttachment(dataType messenger.AttachmentType, name string) error {
+	info, err := os.Stat(name)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s is a directory", name)
+	}
+	if info.Size() > maxAttachmentSize {
+		return fmt.Errorf("%s is %d bytes, larger than the 25MB limit", name, info.Size())
+	}
+
+	if dataType == messenger.FileAttachment {
+		return nil
+	}
+
+	contentType, err := detectContentType(name)
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(contentType, string(dataType)+"/") {
+		return fmt.Errorf("%s is %s, expected %s/*", name, contentType, dataType)
+	}
+
+	return nil
+}
+
+// detectContentType sniffs the file content, falling back to the extension.
+func detectContentType(name string) (string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	buf := make([]byte, 512)
+	n, _ := f.Read(buf)
+	contentType := http.DetectContentType(buf[:n])
+	if contentType == "application/octet-stream" {
+		if byExt := mime.TypeByExtension(filepath.Ext(name)); byExt != "" {
+			contentType = byExt
+		}
+	}
+
+	return contentType, nil
+}
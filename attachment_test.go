@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paked/messenger"
+	"github.com/stretchr/testify/assert"
+)
+
+// a 1x1 transparent png
+var pngData = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01\x00\x00\x00\x01\x08\x06\x00\x00\x00\x1f\x15\xc4\x89\x00\x00\x00\rIDATx\x9cc\xf8\x0f\x00\x00\x01\x01\x00\x05\x18\xd8N\x00\x00\x00\x00IEND\xaeB`\x82")
+
+func TestAttachments(t *testing.T) {
+	dir, err := ioutil.TempDir("", "drone-facebook")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "screenshots"), 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "screenshots", "b.png"), pngData, 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "screenshots", "a.png"), pngData, 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "report.html"), []byte("<html></html>"), 0644))
+
+	plugin := Plugin{
+		Config: Config{
+			Workspace: dir,
+			Image:     []string{"https://example.com/1.png", "screenshots/*.png"},
+			File:      []string{"report.html"},
+		},
+	}
+
+	parts, err := plugin.attachments()
+	assert.NoError(t, err)
+	assert.Len(t, parts, 4)
+	assert.Equal(t, attachmentMessage(messenger.ImageAttachment, "https://example.com/1.png"), parts[0].message)
+	assert.Equal(t, filepath.Join(dir, "screenshots", "a.png"), parts[1].file)
+	assert.Equal(t, filepath.Join(dir, "screenshots", "b.png"), parts[2].file)
+	assert.Equal(t, "image", parts[1].message.Attachment.Type)
+	assert.Equal(t, "file", parts[3].kind)
+
+	// missing files and wrong types fail instead of being dropped
+	plugin.Config.Image = []string{"missing.png"}
+	_, err = plugin.attachments()
+	assert.EqualError(t, err, "can't find the image: no file matches "+filepath.Join(dir, "missing.png"))
+
+	plugin.Config.Image = []string{"report.html"}
+	_, err = plugin.extras()
+	assert.EqualError(t, err, "can't upload the image: "+filepath.Join(dir, "report.html")+" is text/html; charset=utf-8, expected image/*")
+}
+
+func TestGraphClientUpload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "drone-facebook")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	name := filepath.Join(dir, "coverage.png")
+	assert.NoError(t, ioutil.WriteFile(name, pngData, 0644))
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		assert.NoError(t, err)
+		assert.Equal(t, "multipart/form-data", mediaType)
+
+		form, err := multipart.NewReader(r.Body, params["boundary"]).ReadForm(1 << 20)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{`{"id":"1"}`}, form.Value["recipient"])
+		assert.Equal(t, []string{"RESPONSE"}, form.Value["messaging_type"])
+		assert.Equal(t, []string{`{"attachment":{"type":"image","payload":{}}}`}, form.Value["message"])
+
+		file := form.File["filedata"][0]
+		assert.Equal(t, "coverage.png", file.Filename)
+		assert.Equal(t, "image/png", file.Header.Get("Content-Type"))
+		f, _ := file.Open()
+		data, _ := ioutil.ReadAll(f)
+		assert.True(t, bytes.Equal(pngData, data))
+
+		w.Write([]byte(`{"recipient_id":"1","message_id":"mid"}`))
+	}))
+	defer ts.Close()
+
+	client := newGraphClient("token")
+	client.url = ts.URL
+
+	req := Plugin{}.request(1, uploadMessage(messenger.ImageAttachment))
+	req.File = name
+	assert.NoError(t, client.Dispatch(req))
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var coAuthorPattern = regexp.MustCompile(`(?mi)^co-authored-by:.*<([^>]+)>\s*$`)
+
+// authorEmails returns the commit author and the co-authors of the
+// Co-authored-by trailers in the commit message.
+func (p Plugin) authorEmails() []string {
+	var emails []string
+	if p.Commit.Email != "" {
+		emails = append(emails, p.Commit.Email)
+	}
+
+	for _, match := range coAuthorPattern.FindAllStringSubmatch(p.Commit.Message, -1) {
+		emails = append(emails, strings.TrimSpace(match[1]))
+	}
+
+	return emails
+}
+
+// recipients returns the IDs of the to setting, or in author_only mode
+// the IDs of the commit authors with author_fallback when nobody matches.
+func (p Plugin) recipients(dir *Directory) ([]int64, error) {
+	if !p.Config.AuthorOnly {
+		return p.resolveTo(dir, p.Config.To)
+	}
+
+	if ids := p.authorIDs(dir); len(ids) > 0 {
+		return ids, nil
+	}
+
+	log.Println("no recipient found for the commit authors, notify the fallback recipients")
+	return p.resolveTo(dir, p.Config.AuthorFallback)
+}
+
+// authorIDs looks up the commit authors in the recipients file and the
+// id:email entries of the to setting.
+func (p Plugin) authorIDs(dir *Directory) []int64 {
+	known := make(map[string][]int64)
+
+	if dir != nil {
+		for _, person := range dir.People {
+			for _, email := range person.Emails {
+				email = strings.ToLower(email)
+				known[email] = append(known[email], person.ID)
+			}
+		}
+	}
+
+	for _, value := range trimElement(p.Config.To) {
+		idArray := trimElement(strings.Split(value, ":"))
+		if len(idArray) < 2 {
+			continue
+		}
+
+		id, err := strconv.ParseInt(idArray[0], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		email := strings.ToLower(idArray[1])
+		known[email] = append(known[email], id)
+	}
+
+	var ids []int64
+	seen := make(map[int64]bool)
+	for _, email := range p.authorEmails() {
+		for _, id := range known[strings.ToLower(email)] {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+
+	return ids
+}
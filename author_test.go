@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthorEmails(t *testing.T) {
+	plugin := Plugin{
+		Commit: Commit{
+			Email:   "alice@example.com",
+			Message: "fix build\n\nCo-authored-by: Bob <bob@example.com>\nco-authored-by: Carol <Carol@Example.com>\n",
+		},
+	}
+
+	assert.Equal(t, []string{"alice@example.com", "bob@example.com", "Carol@Example.com"}, plugin.authorEmails())
+}
+
+func TestAuthorOnly(t *testing.T) {
+	dir := &Directory{
+		People: []Person{
+			{Name: "alice", ID: 1, Emails: []string{"alice@example.com"}},
+			{Name: "carol", ID: 3, Emails: []string{"carol@example.com"}},
+		},
+	}
+	plugin := Plugin{
+		Commit: Commit{
+			Email:   "Alice@example.com",
+			Message: "fix build\n\nCo-authored-by: Bob <bob@example.com>",
+		},
+		Config: Config{
+			To:             []string{"2:bob@example.com", "4"},
+			AuthorOnly:     true,
+			AuthorFallback: []string{"carol"},
+		},
+	}
+
+	ids, err := plugin.recipients(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1, 2}, ids)
+
+	// nobody matches, notify the fallback
+	plugin.Commit = Commit{Email: "dave@example.com"}
+	ids, err = plugin.recipients(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{3}, ids)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aymerick/raymond"
+	"gopkg.in/yaml.v2"
+)
+
+// Send API limits of the generic template.
+const (
+	maxCarouselElements = 10
+	maxElementButtons   = 3
+)
+
+type (
+	// CarouselElement is a card of the carousel setting, every field is
+	// a template rendered with the plugin values.
+	CarouselElement struct {
+		Title    string           `yaml:"title"`
+		Subtitle string           `yaml:"subtitle"`
+		ImageURL string           `yaml:"image_url"`
+		URL      string           `yaml:"url"`
+		Buttons  []CarouselButton `yaml:"buttons"`
+	}
+
+	// CarouselButton is a URL button of a carousel card.
+	CarouselButton struct {
+		Title string `yaml:"title"`
+		URL   string `yaml:"url"`
+	}
+)
+
+// carousel parses and renders the carousel setting into generic
+// template elements. It returns nil when no carousel is configured.
+func (p Plugin) carousel() ([]elementData, error) {
+	if p.Config.Carousel == "" {
+		return nil, nil
+	}
+
+	var items []CarouselElement
+	if err := yaml.UnmarshalStrict([]byte(p.Config.Carousel), &items); err != nil {
+		return nil, fmt.Errorf("can't parse carousel: %v", err)
+	}
+
+	if len(items) > maxCarouselElements {
+		return nil, fmt.Errorf("carousel has %d elements, at most %d allowed", len(items), maxCarouselElements)
+	}
+
+	var elements []elementData
+	for i, item := range items {
+		if len(item.Buttons) > maxElementButtons {
+			return nil, fmt.Errorf("carousel element %d has %d buttons, at most %d allowed", i+1, len(item.Buttons), maxElementButtons)
+		}
+
+		element, err := p.renderElement(item)
+		if err != nil {
+			return nil, fmt.Errorf("carousel element %d: %v", i+1, err)
+		}
+		elements = append(elements, element)
+	}
+
+	return elements, nil
+}
+
+func (p Plugin) renderElement(item CarouselElement) (elementData, error) {
+	var element elementData
+	var err error
+
+	if element.Title, err = p.render(item.Title); err != nil {
+		return element, err
+	}
+	if element.Title == "" {
+		return element, errors.New("missing title")
+	}
+	element.Title = truncate(element.Title, maxElementTitle)
+
+	if element.Subtitle, err = p.render(item.Subtitle); err != nil {
+		return element, err
+	}
+	element.Subtitle = truncate(element.Subtitle, maxElementSubtitle)
+
+	if element.ImageURL, err = p.render(item.ImageURL); err != nil {
+		return element, err
+	}
+
+	if item.URL != "" {
+		url, err := p.render(item.URL)
+		if err != nil {
+			return element, err
+		}
+		element.DefaultAction = &defaultActionData{Type: "web_url", URL: url}
+	}
+
+	for _, button := range item.Buttons {
+		title, err := p.render(button.Title)
+		if err != nil {
+			return element, err
+		}
+		url, err := p.render(button.URL)
+		if err != nil {
+			return element, err
+		}
+		element.Buttons = append(element.Buttons, urlButton(title, url))
+	}
+
+	return element, nil
+}
+
+// render executes a template field. Unlike template.RenderTrim it never
+// fetches the value, since most fields are URLs.
+func (p Plugin) render(value string) (string, error) {
+	out, err := raymond.Render(value, p)
+	return strings.Trim(out, " \n"), err
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCarousel(t *testing.T) {
+	plugin := Plugin{
+		Repo: Repo{
+			Name: "go-hello",
+		},
+		Build: Build{
+			Tag: "v1.0.0",
+		},
+		Config: Config{
+			To: []string{"1"},
+			Carousel: `
+- title: "{{ repo.name }} {{ build.tag }}"
+  subtitle: linux/amd64
+  image_url: https://example.com/linux.png
+  url: https://example.com/releases/{{ build.tag }}
+  buttons:
+    - title: Download
+      url: https://example.com/releases/{{ build.tag }}/linux-amd64.tar.gz
+- title: windows
+`,
+		},
+	}
+
+	elements, err := plugin.carousel()
+	assert.NoError(t, err)
+	assert.Equal(t, []elementData{
+		{
+			Title:         "go-hello v1.0.0",
+			Subtitle:      "linux/amd64",
+			ImageURL:      "https://example.com/linux.png",
+			DefaultAction: &defaultActionData{Type: "web_url", URL: "https://example.com/releases/v1.0.0"},
+			Buttons: []buttonData{
+				{Type: "web_url", Title: "Download", URL: "https://example.com/releases/v1.0.0/linux-amd64.tar.gz"},
+			},
+		},
+		{
+			Title: "windows",
+		},
+	}, elements)
+
+	client := &fakeSender{}
+	plugin.Config.Message = []string{"released"}
+	assert.NoError(t, plugin.send(client, nil))
+	assert.Equal(t, []string{"released", "template:generic"}, client.sent[1])
+
+	plugin.Config.Carousel = "- subtitle: no title"
+	_, err = plugin.carousel()
+	assert.EqualError(t, err, "carousel element 1: missing title")
+
+	plugin.Config.Carousel = strings.Repeat("- title: x\n", 11)
+	_, err = plugin.carousel()
+	assert.EqualError(t, err, "carousel has 11 elements, at most 10 allowed")
+}
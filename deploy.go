@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/paked/messenger"
+)
+
+// AuditEntry records a build action run from chat.
+type AuditEntry struct {
+	Time   time.Time `json:"time"`
+	User   int64     `json:"user"`
+	Name   string    `json:"name,omitempty"`
+	Action string    `json:"action"`
+	Repo   string    `json:"repo"`
+	Build  int       `json:"build"`
+	Target string    `json:"target,omitempty"`
+	Result string    `json:"result"`
+}
+
+var auditMu sync.Mutex
+
+// Restart restarts the build and returns the new build.
+func (d *droneClient) Restart(repo string, number int) (*droneBuild, error) {
+	build := &droneBuild{}
+	if err := d.do("POST", repoPath(repo)+"/builds/"+strconv.Itoa(number), nil, build); err != nil {
+		return nil, err
+	}
+
+	return build, nil
+}
+
+// Promote promotes the build to the target environment and returns the
+// deployment build.
+func (d *droneClient) Promote(repo string, number int, target string) (*droneBuild, error) {
+	query := url.Values{}
+	query.Set("target", target)
+
+	build := &droneBuild{}
+	if err := d.do("POST", repoPath(repo)+"/builds/"+strconv.Itoa(number)+"/promote", query, build); err != nil {
+		return nil, err
+	}
+
+	return build, nil
+}
+
+// admin reports whether the user may restart and promote builds.
+func (p Plugin) admin(user int64) bool {
+	id := strconv.FormatInt(user, 10)
+	for _, admin := range trimElement(p.Config.Admins) {
+		if admin == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// deployCommands are the chat commands restarting and promoting builds,
+// restricted to the admins.
+func (p Plugin) deployCommands() []Command {
+	return []Command{
+		{
+			Name:      "restart",
+			Args:      "owner/repo <build>",
+			Help:      "restart a build",
+			MinArgs:   2,
+			MaxArgs:   2,
+			Authorize: p.admin,
+			Run: func(ctx *Context) string {
+				return p.requestAction(ctx, ActionRestart)
+			},
+		},
+		{
+			Name:      "promote",
+			Args:      "owner/repo <build> <environment>",
+			Help:      "promote a build to an environment",
+			MinArgs:   3,
+			MaxArgs:   3,
+			Authorize: p.admin,
+			Run: func(ctx *Context) string {
+				return p.requestAction(ctx, ActionPromote)
+			},
+		},
+	}
+}
+
+// requestAction parses the restart and promote arguments and asks for a
+// confirmation.
+func (p Plugin) requestAction(ctx *Context, name string) string {
+	repo := ctx.Args[0]
+	build, err := strconv.Atoi(ctx.Args[1])
+	if !validRepo(repo) || err != nil || build < 1 {
+		return ctx.Usage()
+	}
+
+	action := Action{Name: name, Repo: repo, Build: build}
+	if name == ActionPromote {
+		action.Target = ctx.Args[2]
+	}
+
+	return p.confirm(ctx, action)
+}
+
+// confirm attaches Confirm and Cancel quick replies to a question about
+// the action.
+func (p Plugin) confirm(ctx *Context, action Action) string {
+	if p.drone() == nil {
+		return noDrone
+	}
+	if !p.admin(ctx.User) {
+		return fmt.Sprintf("You are not allowed to %s builds.", action.Name)
+	}
+
+	cancel := action
+	cancel.Name = ActionCancel
+	ctx.QuickReplies = []messenger.QuickReply{
+		{ContentType: "text", Title: "Confirm", Payload: action.Payload()},
+		{ContentType: "text", Title: "Cancel", Payload: cancel.Payload()},
+	}
+
+	if action.Name == ActionPromote {
+		return fmt.Sprintf("Promote build #%d of %s to %s?", action.Build, action.Repo, action.Target)
+	}
+
+	return fmt.Sprintf("Restart build #%d of %s?", action.Build, action.Repo)
+}
+
+// runConfirmed runs a confirmed restart or promotion and records it in
+// the audit log.
+func (p Plugin) runConfirmed(ctx *Context, action Action) string {
+	client := p.drone()
+	if client == nil {
+		return noDrone
+	}
+	if !p.admin(ctx.User) {
+		return "You are not allowed to run build actions."
+	}
+
+	entry := AuditEntry{
+		User:   ctx.User,
+		Name:   ctx.Name,
+		Action: action.Name,
+		Repo:   action.Repo,
+		Build:  action.Build,
+		Target: action.Target,
+	}
+
+	var (
+		build *droneBuild
+		err   error
+	)
+	switch action.Name {
+	case ActionCancel:
+		entry.Result = "cancelled"
+		p.audit(entry)
+		return "Cancelled."
+	case ActionRestart:
+		build, err = client.Restart(action.Repo, action.Build)
+	case ActionPromote:
+		build, err = client.Promote(action.Repo, action.Build, action.Target)
+	}
+
+	if err != nil {
+		entry.Result = err.Error()
+		p.audit(entry)
+		return droneReply(action.Repo, err)
+	}
+
+	entry.Result = fmt.Sprintf("build #%d", build.Number)
+	p.audit(entry)
+
+	return fmt.Sprintf("Started build #%d of %s.\n%s", build.Number, action.Repo, client.link(action.Repo, build.Number))
+}
+
+// audit appends the entry to the audit log file as a json line, or logs
+// it when no file is configured.
+func (p Plugin) audit(entry AuditEntry) {
+	entry.Time = time.Now().UTC()
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		log.Println("error to write the audit log:", err)
+		return
+	}
+
+	if p.Config.AuditLog == "" {
+		log.Printf("audit: %s\n", data)
+		return
+	}
+
+	auditMu.Lock()
+	defer auditMu.Unlock()
+
+	f, err := os.OpenFile(p.Config.AuditLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		log.Println("error to write the audit log:", err)
+		return
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		log.Println("error to write the audit log:", err)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeployCommands(t *testing.T) {
+	dir, err := ioutil.TempDir("", "drone-facebook")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	var calls []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "POST", r.Method)
+		calls = append(calls, r.URL.String())
+
+		switch r.URL.Path {
+		case "/api/repos/appleboy/go-hello/builds/12":
+			fmt.Fprint(w, `{"number":13,"status":"pending"}`)
+		case "/api/repos/appleboy/go-hello/builds/12/promote":
+			fmt.Fprint(w, `{"number":14,"status":"pending","event":"promote"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message":"Not Found"}`)
+		}
+	}))
+	defer ts.Close()
+
+	audit := filepath.Join(dir, "audit.log")
+	plugin := Plugin{
+		Config: Config{
+			DroneServer: ts.URL,
+			DroneToken:  "secret",
+			Admins:      []string{"1"},
+			AuditLog:    audit,
+		},
+	}
+	router := plugin.Router()
+
+	// the restart waits for a confirmation
+	ctx := &Context{User: 1, Name: "Bo-Yi"}
+	assert.Equal(t, "Restart build #12 of appleboy/go-hello?", router.Handle(ctx, "restart appleboy/go-hello 12"))
+	assert.Len(t, ctx.QuickReplies, 2)
+	assert.Empty(t, calls)
+
+	action, ok := parseAction(ctx.QuickReplies[0].Payload)
+	assert.True(t, ok)
+	assert.Equal(t, "Started build #13 of appleboy/go-hello.\n"+ts.URL+"/appleboy/go-hello/13", plugin.handleAction(ctx, action))
+
+	// promotions keep the target
+	ctx = &Context{User: 1, Name: "Bo-Yi"}
+	assert.Equal(t, "Promote build #12 of appleboy/go-hello to production?", router.Handle(ctx, "promote appleboy/go-hello 12 production"))
+	action, _ = parseAction(ctx.QuickReplies[0].Payload)
+	assert.Equal(t, Action{Name: ActionPromote, Repo: "appleboy/go-hello", Build: 12, Target: "production"}, action)
+	assert.Equal(t, "Started build #14 of appleboy/go-hello.\n"+ts.URL+"/appleboy/go-hello/14", plugin.handleAction(ctx, action))
+
+	cancel, _ := parseAction(ctx.QuickReplies[1].Payload)
+	assert.Equal(t, "Cancelled.", plugin.handleAction(ctx, cancel))
+
+	assert.Equal(t, []string{
+		"/api/repos/appleboy/go-hello/builds/12",
+		"/api/repos/appleboy/go-hello/builds/12/promote?target=production",
+	}, calls)
+
+	// other users can't run or confirm build actions
+	user := &Context{User: 2}
+	assert.Equal(t, "You are not allowed to run restart.", router.Handle(user, "restart appleboy/go-hello 12"))
+	assert.Equal(t, "You are not allowed to run build actions.", plugin.handleAction(user, action))
+	assert.Equal(t, "You are not allowed to restart builds.", plugin.handleAction(user, Action{Name: ActionRebuild, Repo: "appleboy/go-hello", Build: 12}))
+	assert.Equal(t, "Usage: restart owner/repo <build>", router.Handle(ctx, "restart appleboy/go-hello latest"))
+
+	// the rebuild quick reply of failure notifications asks for a confirmation
+	ctx = &Context{User: 1}
+	assert.Equal(t, "Restart build #12 of appleboy/go-hello?", plugin.handleAction(ctx, Action{Name: ActionRebuild, Repo: "appleboy/go-hello", Build: 12}))
+	assert.Equal(t, "drone:restart:appleboy/go-hello:12", ctx.QuickReplies[0].Payload)
+
+	data, err := ioutil.ReadFile(audit)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	assert.Len(t, lines, 3)
+
+	var entry AuditEntry
+	assert.NoError(t, json.Unmarshal([]byte(lines[1]), &entry))
+	assert.Equal(t, int64(1), entry.User)
+	assert.Equal(t, "Bo-Yi", entry.Name)
+	assert.Equal(t, ActionPromote, entry.Action)
+	assert.Equal(t, "production", entry.Target)
+	assert.Equal(t, "build #14", entry.Result)
+	assert.False(t, entry.Time.IsZero())
+}
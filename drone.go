@@ -0,0 +1,273 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxBuilds is how many builds the builds command lists.
+const maxBuilds = 5
+
+type (
+	// DroneError is an error returned by the Drone server API.
+	DroneError struct {
+		StatusCode int    `json:"-"`
+		Message    string `json:"message"`
+	}
+
+	// droneBuild is a build of the Drone server API.
+	droneBuild struct {
+		Number  int    `json:"number"`
+		Status  string `json:"status"`
+		Event   string `json:"event"`
+		Target  string `json:"target"`
+		Author  string `json:"author_login"`
+		Message string `json:"message"`
+	}
+
+	// droneClient talks to the Drone server REST API.
+	droneClient struct {
+		server string
+		token  string
+		client *http.Client
+	}
+)
+
+func (e *DroneError) Error() string {
+	return fmt.Sprintf("Drone error : %s (status %d)", e.Message, e.StatusCode)
+}
+
+func newDroneClient(server, token string) *droneClient {
+	return &droneClient{
+		server: strings.TrimRight(server, "/"),
+		token:  token,
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}
+}
+
+// Builds returns the latest builds of the repository.
+func (d *droneClient) Builds(repo string) ([]droneBuild, error) {
+	var builds []droneBuild
+	if err := d.do("GET", repoPath(repo)+"/builds", nil, &builds); err != nil {
+		return nil, err
+	}
+
+	return builds, nil
+}
+
+// LatestBuild returns the latest build of the repository, of the branch
+// when not empty.
+func (d *droneClient) LatestBuild(repo, branch string) (*droneBuild, error) {
+	query := url.Values{}
+	if branch != "" {
+		query.Set("branch", branch)
+	}
+
+	build := &droneBuild{}
+	if err := d.do("GET", repoPath(repo)+"/builds/latest", query, build); err != nil {
+		return nil, err
+	}
+
+	return build, nil
+}
+
+// repoPath is the API path of an owner/name repository.
+func repoPath(repo string) string {
+	parts := strings.SplitN(repo, "/", 2)
+	return "/api/repos/" + url.PathEscape(parts[0]) + "/" + url.PathEscape(parts[1])
+}
+
+// validRepo reports whether repo is an owner/name repository.
+func validRepo(repo string) bool {
+	parts := strings.Split(repo, "/")
+	if len(parts) != 2 {
+		return false
+	}
+
+	for _, part := range parts {
+		if part == "" || part == "." || part == ".." {
+			return false
+		}
+	}
+
+	return true
+}
+
+// link returns the page of the build on the Drone server.
+func (d *droneClient) link(repo string, number int) string {
+	return d.server + "/" + repo + "/" + strconv.Itoa(number)
+}
+
+func (d *droneClient) do(method, path string, query url.Values, out interface{}) error {
+	req, err := http.NewRequest(method, d.server+path, nil)
+	if err != nil {
+		return err
+	}
+
+	req.URL.RawQuery = query.Encode()
+	req.Header.Set("Authorization", "Bearer "+d.token)
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		droneErr := &DroneError{StatusCode: resp.StatusCode}
+		if err := json.Unmarshal(body, droneErr); err != nil || droneErr.Message == "" {
+			droneErr.Message = strings.TrimSpace(string(body))
+		}
+		return droneErr
+	}
+
+	if out != nil {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+
+	return nil
+}
+
+// drone returns the Drone server client, nil when not configured.
+func (p Plugin) drone() *droneClient {
+	if p.Config.DroneServer == "" || p.Config.DroneToken == "" {
+		return nil
+	}
+
+	return newDroneClient(p.Config.DroneServer, p.Config.DroneToken)
+}
+
+const noDrone = "Build commands are not available, the webhook server has no drone server configured."
+
+// viewer reports whether the user may query builds, the admins and the
+// viewers.
+func (p Plugin) viewer(user int64) bool {
+	if p.admin(user) {
+		return true
+	}
+
+	id := strconv.FormatInt(user, 10)
+	for _, viewer := range trimElement(p.Config.Viewers) {
+		if viewer == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// droneCommands are the chat commands querying the Drone server,
+// restricted to the viewers.
+func (p Plugin) droneCommands() []Command {
+	client := p.drone()
+
+	return []Command{
+		{
+			Name:      "status",
+			Args:      "owner/repo [branch]",
+			Help:      "show the latest build of a repository, optionally of a branch",
+			MinArgs:   1,
+			MaxArgs:   2,
+			Authorize: p.viewer,
+			Run: func(ctx *Context) string {
+				return buildStatus(client, ctx)
+			},
+		},
+		{
+			Name:      "builds",
+			Args:      "owner/repo",
+			Help:      fmt.Sprintf("list the %d latest builds of a repository", maxBuilds),
+			MinArgs:   1,
+			MaxArgs:   1,
+			Authorize: p.viewer,
+			Run: func(ctx *Context) string {
+				return buildList(client, ctx)
+			},
+		},
+	}
+}
+
+func buildStatus(client *droneClient, ctx *Context) string {
+	if client == nil {
+		return noDrone
+	}
+
+	repo := ctx.Args[0]
+	if !validRepo(repo) {
+		return ctx.Usage()
+	}
+
+	branch := ""
+	if len(ctx.Args) == 2 {
+		branch = ctx.Args[1]
+	}
+
+	build, err := client.LatestBuild(repo, branch)
+	if err != nil {
+		return droneReply(repo, err)
+	}
+
+	return repo + " " + describeBuild(build) + "\n" + client.link(repo, build.Number)
+}
+
+func buildList(client *droneClient, ctx *Context) string {
+	if client == nil {
+		return noDrone
+	}
+
+	repo := ctx.Args[0]
+	if !validRepo(repo) {
+		return ctx.Usage()
+	}
+
+	builds, err := client.Builds(repo)
+	if err != nil {
+		return droneReply(repo, err)
+	}
+	if len(builds) == 0 {
+		return fmt.Sprintf("No builds of %s yet.", repo)
+	}
+	if len(builds) > maxBuilds {
+		builds = builds[:maxBuilds]
+	}
+
+	lines := []string{repo + " builds:"}
+	for i := range builds {
+		lines = append(lines, "- "+describeBuild(&builds[i])+" "+client.link(repo, builds[i].Number))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// describeBuild is a one line summary of the build.
+func describeBuild(build *droneBuild) string {
+	text := fmt.Sprintf("#%d %s", build.Number, build.Status)
+	if build.Target != "" {
+		text += " on " + build.Target
+	}
+	if build.Author != "" {
+		text += " by " + build.Author
+	}
+
+	return text
+}
+
+// droneReply explains a failed Drone server call.
+func droneReply(repo string, err error) string {
+	if e, ok := err.(*DroneError); ok && e.StatusCode == http.StatusNotFound {
+		return fmt.Sprintf("No builds found for %s.", repo)
+	}
+
+	log.Println("error to query the drone server:", err)
+	return "Something went wrong, try again later."
+}
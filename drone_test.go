@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDroneCommands(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+
+		switch r.URL.Path {
+		case "/api/repos/appleboy/go-hello/builds/latest":
+			if r.URL.Query().Get("branch") == "develop" {
+				fmt.Fprint(w, `{"number":11,"status":"failure","target":"develop","author_login":"bo"}`)
+				return
+			}
+			fmt.Fprint(w, `{"number":12,"status":"success","target":"master","author_login":"appleboy"}`)
+		case "/api/repos/appleboy/go-hello/builds":
+			fmt.Fprint(w, `[
+				{"number":12,"status":"success","target":"master","author_login":"appleboy"},
+				{"number":11,"status":"failure","target":"develop","author_login":"bo"}
+			]`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message":"Not Found"}`)
+		}
+	}))
+	defer ts.Close()
+
+	plugin := Plugin{
+		Config: Config{
+			DroneServer: ts.URL + "/",
+			DroneToken:  "secret",
+			Viewers:     []string{"1"},
+		},
+	}
+	router := plugin.Router()
+	ctx := func() *Context { return &Context{User: 1} }
+
+	assert.Equal(t, "appleboy/go-hello #12 success on master by appleboy\n"+ts.URL+"/appleboy/go-hello/12",
+		router.Handle(ctx(), "status appleboy/go-hello"))
+	assert.Equal(t, "appleboy/go-hello #11 failure on develop by bo\n"+ts.URL+"/appleboy/go-hello/11",
+		router.Handle(ctx(), "status appleboy/go-hello develop"))
+	assert.Equal(t, "appleboy/go-hello builds:\n"+
+		"- #12 success on master by appleboy "+ts.URL+"/appleboy/go-hello/12\n"+
+		"- #11 failure on develop by bo "+ts.URL+"/appleboy/go-hello/11",
+		router.Handle(ctx(), "builds appleboy/go-hello"))
+	assert.Equal(t, "No builds found for appleboy/missing.", router.Handle(ctx(), "status appleboy/missing"))
+	assert.Equal(t, "Usage: status owner/repo [branch]", router.Handle(ctx(), "status appleboy/../admin"))
+
+	// other users can't query builds
+	assert.Equal(t, "You are not allowed to run status.", router.Handle(&Context{User: 2}, "status appleboy/go-hello"))
+	assert.Equal(t, "You are not allowed to run builds.", router.Handle(&Context{User: 2}, "builds appleboy/go-hello"))
+
+	plugin = Plugin{Config: Config{Admins: []string{"1"}}}
+	assert.Equal(t, noDrone, plugin.Router().Handle(ctx(), "builds appleboy/go-hello"))
+}
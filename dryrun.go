@@ -0,0 +1,39 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/paked/messenger"
+)
+
+// dryRunSender prints the Send API request bodies instead of calling Facebook.
+type dryRunSender struct {
+	sync.Mutex
+	out io.Writer
+}
+
+// Dispatch prints the request.
+func (d *dryRunSender) Dispatch(req *sendRequest) error {
+	return d.print(req, req.File)
+}
+
+func (d *dryRunSender) print(m interface{}, file string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	d.Lock()
+	defer d.Unlock()
+	if _, err := fmt.Fprintf(d.out, "POST %s\n%s\n", messenger.SendMessageURL, data); err != nil {
+		return err
+	}
+	if file != "" {
+		_, err = fmt.Fprintf(d.out, "filedata=@%s\n", file)
+	}
+
+	return err
+}
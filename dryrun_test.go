@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDryRun(t *testing.T) {
+	plugin := Plugin{
+		Build: Build{
+			Number: 101,
+		},
+		Config: Config{
+			To:      []string{"1234"},
+			Message: []string{"build {{ build.number }}"},
+			Image:   []string{"https://example.com/1.png"},
+			DryRun:  true,
+		},
+	}
+
+	var out bytes.Buffer
+	assert.NoError(t, plugin.send(&dryRunSender{out: &out}, nil))
+	assert.Equal(t, `POST https://graph.facebook.com/v2.11/me/messages
+{
+  "messaging_type": "RESPONSE",
+  "recipient": {
+    "id": "1234"
+  },
+  "message": {
+    "text": "build 101"
+  }
+}
+POST https://graph.facebook.com/v2.11/me/messages
+{
+  "messaging_type": "RESPONSE",
+  "recipient": {
+    "id": "1234"
+  },
+  "message": {
+    "attachment": {
+      "type": "image",
+      "payload": {
+        "url": "https://example.com/1.png"
+      }
+    }
+  }
+}
+`, out.String())
+
+	// no page token needed
+	assert.NoError(t, plugin.Exec())
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"log"
+	"strings"
+
+	"github.com/paked/messenger"
+)
+
+// parseEventActions reads the PAYLOAD=command entries of the postback
+// actions setting.
+func parseEventActions(values []string) map[string]string {
+	actions := make(map[string]string)
+	for _, value := range trimElement(values) {
+		kv := strings.SplitN(value, "=", 2)
+		if len(kv) != 2 {
+			log.Println("invalid postback action, expected PAYLOAD=command:", value)
+			continue
+		}
+		actions[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+
+	return actions
+}
+
+// handlePostBack runs a button tap: quick reply actions, then the
+// command configured for the payload, else the payload as a command.
+func (p Plugin) handlePostBack(ctx *Context, router *Router, payload string) string {
+	if action, ok := parseAction(payload); ok {
+		return p.handleAction(ctx, action)
+	}
+
+	if command, ok := parseEventActions(p.Config.PostbackActions)[payload]; ok {
+		return router.Handle(ctx, command)
+	}
+
+	return router.Handle(ctx, payload)
+}
+
+// handleRef runs the command configured for referrals or opt-ins with
+// the ref as arguments, a ref like owner/repo:branch runs
+// "subscribe owner/repo branch". It returns false when there is nothing
+// to run.
+func handleRef(ctx *Context, router *Router, command, ref string) (string, bool) {
+	if command == "" || ref == "" {
+		return "", false
+	}
+
+	return router.Handle(ctx, command+" "+strings.Replace(ref, ":", " ", -1)), true
+}
+
+// respond sends the reply with the quick replies the command attached.
+func respond(r *messenger.Response, ctx *Context, reply string) {
+	var err error
+	if len(ctx.QuickReplies) > 0 {
+		err = r.TextWithReplies(reply, ctx.QuickReplies, messenger.ResponseType)
+	} else {
+		err = r.Text(reply, messenger.ResponseType)
+	}
+
+	if err != nil {
+		log.Println("Something went wrong!", err)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseEventActions(t *testing.T) {
+	assert.Equal(t, map[string]string{
+		"GET_STARTED": "help",
+		"STATUS":      "status appleboy/go-hello",
+	}, parseEventActions([]string{"GET_STARTED=help", " STATUS = status appleboy/go-hello", "broken"}))
+}
+
+func TestEvents(t *testing.T) {
+	dir, err := ioutil.TempDir("", "drone-facebook")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	store, err := OpenStore(filepath.Join(dir, "store.json"))
+	assert.NoError(t, err)
+
+	plugin := Plugin{
+		Config: Config{
+			PostbackActions: []string{"GET_STARTED=list"},
+			SubscribeRepos:  []string{"appleboy/*"},
+		},
+	}
+	router := plugin.Router()
+	ctx := &Context{User: 1, Store: store}
+
+	// m.me/page?ref=appleboy/go-hello:master
+	reply, ok := handleRef(ctx, router, "subscribe", "appleboy/go-hello:master")
+	assert.True(t, ok)
+	assert.Equal(t, "Subscribed to appleboy/go-hello (master).", reply)
+	assert.Equal(t, []int64{1}, store.Subscribers("appleboy/go-hello", "master"))
+
+	_, ok = handleRef(ctx, router, "", "appleboy/go-hello")
+	assert.False(t, ok)
+	_, ok = handleRef(ctx, router, "subscribe", "")
+	assert.False(t, ok)
+
+	// configured payloads, then the payload as a command
+	assert.Equal(t, "Subscriptions:\n- appleboy/go-hello (master)", plugin.handlePostBack(ctx, router, "GET_STARTED"))
+	assert.Equal(t, "Unsubscribed from appleboy/go-hello.", plugin.handlePostBack(ctx, router, "unsubscribe appleboy/go-hello"))
+	assert.Equal(t, `Unknown command "NOPE", send "help" to list the commands.`, plugin.handlePostBack(ctx, router, "NOPE"))
+
+	// quick reply payloads sent as postbacks
+	action := Action{Name: ActionMute, Repo: "appleboy/go-hello", Build: 1}
+	assert.Equal(t, "Muted appleboy/go-hello for 1 hour.", plugin.handlePostBack(ctx, router, action.Payload()))
+}
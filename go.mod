@@ -3,6 +3,7 @@ module github.com/appleboy/drone-facebook
 go 1.12
 
 require (
+	github.com/aymerick/raymond v2.0.2+incompatible
 	github.com/drone/drone-template-lib v1.0.0
 	github.com/joho/godotenv v1.3.0
 	github.com/paked/messenger v1.1.1
@@ -10,5 +11,5 @@ require (
 	github.com/stretchr/testify v1.2.2
 	github.com/urfave/cli v1.20.0
 	golang.org/x/crypto v0.0.0-20190426145343-a29dc8fdc734
-	gopkg.in/yaml.v2 v2.2.4 // indirect
+	gopkg.in/yaml.v2 v2.2.4
 )
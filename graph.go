@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/paked/messenger"
+)
+
+// graphURL is the Graph API version the Send API client uses.
+const graphURL = "https://graph.facebook.com/v2.11"
+
+// GraphError is an error object returned by the Facebook Graph API.
+type GraphError struct {
+	StatusCode int    `json:"-"`
+	Message    string `json:"message"`
+	Type       string `json:"type"`
+	Code       int    `json:"code"`
+	Subcode    int    `json:"error_subcode"`
+	FBTraceID  string `json:"fbtrace_id"`
+}
+
+func (e *GraphError) Error() string {
+	if e.OutsideWindow() {
+		return fmt.Sprintf("Facebook error : %s (code %d, subcode %d), "+
+			"the recipient has not messaged the page in the last 24 hours, "+
+			"send with the MESSAGE_TAG messaging type and a tag to reach them", e.Message, e.Code, e.Subcode)
+	}
+
+	if e.Subcode != 0 {
+		return fmt.Sprintf("Facebook error : %s (code %d, subcode %d)", e.Message, e.Code, e.Subcode)
+	}
+
+	return fmt.Sprintf("Facebook error : %s (code %d)", e.Message, e.Code)
+}
+
+// OutsideWindow reports whether the message was rejected by the 24 hours
+// standard messaging window.
+func (e *GraphError) OutsideWindow() bool {
+	return e.Code == 10 && e.Subcode == 2018278
+}
+
+// graphClient talks to the Send API directly so the Graph API error
+// codes are kept, which the messenger client drops.
+type graphClient struct {
+	token     string
+	url       string
+	uploadURL string
+	graphURL  string
+	client    *http.Client
+}
+
+func newGraphClient(token string) *graphClient {
+	return &graphClient{
+		token:     token,
+		url:       messenger.SendMessageURL,
+		uploadURL: attachmentUploadURL,
+		graphURL:  graphURL,
+		client: &http.Client{
+			Timeout: 2 * time.Minute,
+		},
+	}
+}
+
+// Dispatch posts the request to the Send API, as a multipart form when
+// it uploads a local file.
+func (g *graphClient) Dispatch(req *sendRequest) error {
+	if req.File != "" {
+		body, contentType, err := multipartBody(req, req.File)
+		if err != nil {
+			return err
+		}
+		return g.do("POST", g.url, body, contentType, nil)
+	}
+
+	return g.post(g.url, req, nil)
+}
+
+// post sends m as json and decodes the response into out when not nil.
+func (g *graphClient) post(url string, m interface{}, out interface{}) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	return g.do("POST", url, bytes.NewBuffer(data), "application/json", out)
+}
+
+func (g *graphClient) do(method, url string, body io.Reader, contentType string, out interface{}) error {
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return err
+	}
+
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	query := req.URL.Query()
+	query.Set("access_token", g.token)
+	req.URL.RawQuery = query.Encode()
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(resp); err != nil {
+		return err
+	}
+
+	if out != nil {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+
+	return nil
+}
+
+// multipartBody encodes the request fields as form values and the local
+// file as filedata.
+func multipartBody(m interface{}, file string) (*bytes.Buffer, string, error) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, "", err
+	}
+
+	body := &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := string(fields[key])
+		var text string
+		if err := json.Unmarshal(fields[key], &text); err == nil {
+			value = text
+		}
+		if err := w.WriteField(key, value); err != nil {
+			return nil, "", err
+		}
+	}
+
+	contentType, err := detectContentType(file)
+	if err != nil {
+		return nil, "", err
+	}
+
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="filedata"; filename=%q`, filepath.Base(file)))
+	h.Set("Content-Type", contentType)
+	part, err := w.CreatePart(h)
+	if err != nil {
+		return nil, "", err
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, "", err
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(part, f); err != nil {
+		return nil, "", err
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, "", err
+	}
+
+	return body, w.FormDataContentType(), nil
+}
+
+// checkResponse turns a non-2xx Graph API response into a GraphError.
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	var result struct {
+		Error *GraphError `json:"error"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil || result.Error == nil {
+		return &GraphError{
+			StatusCode: resp.StatusCode,
+			Message:    http.StatusText(resp.StatusCode),
+		}
+	}
+
+	result.Error.StatusCode = resp.StatusCode
+	return result.Error
+}
@@ -241,6 +241,248 @@ func main() {
 			Usage:  "Provides the target deployment environment for the running build. This value is only available to promotion and rollback pipelines.",
 			EnvVar: "DRONE_DEPLOY_TO",
 		},
+		cli.StringFlag{
+			Name:   "failure.policy",
+			Usage:  "when to fail the step on send errors: any, all or never",
+			EnvVar: "PLUGIN_FAILURE_POLICY,INPUT_FAILURE_POLICY",
+			Value:  FailOnAny,
+		},
+		cli.IntFlag{
+			Name:   "retry.attempts",
+			Usage:  "max attempts per message on transient facebook errors",
+			EnvVar: "PLUGIN_RETRY_ATTEMPTS,INPUT_RETRY_ATTEMPTS",
+			Value:  3,
+		},
+		cli.DurationFlag{
+			Name:   "retry.max.elapsed",
+			Usage:  "max time spent retrying a single message",
+			EnvVar: "PLUGIN_RETRY_MAX_ELAPSED,INPUT_RETRY_MAX_ELAPSED",
+			Value:  30 * time.Second,
+		},
+		cli.IntFlag{
+			Name:   "parallelism",
+			Usage:  "number of recipients notified concurrently",
+			EnvVar: "PLUGIN_PARALLELISM,INPUT_PARALLELISM",
+			Value:  1,
+		},
+		cli.Float64Flag{
+			Name:   "rate.limit",
+			Usage:  "max requests per second to facebook across all recipients, 0 means unlimited",
+			EnvVar: "PLUGIN_RATE_LIMIT,INPUT_RATE_LIMIT",
+		},
+		cli.BoolFlag{
+			Name:   "dry.run",
+			Usage:  "print the messages instead of sending them to facebook",
+			EnvVar: "PLUGIN_DRY_RUN,INPUT_DRY_RUN",
+		},
+		cli.StringSliceFlag{
+			Name:   "template.file",
+			Usage:  "read message templates from files in the workspace",
+			EnvVar: "PLUGIN_TEMPLATE_FILE,INPUT_TEMPLATE_FILE",
+		},
+		cli.StringFlag{
+			Name:   "workspace",
+			Usage:  "workspace path template files are resolved from",
+			EnvVar: "PLUGIN_WORKSPACE,DRONE_WORKSPACE",
+		},
+		cli.StringSliceFlag{
+			Name:   "message.success",
+			Usage:  "message template for successful builds",
+			EnvVar: "PLUGIN_MESSAGE_SUCCESS,INPUT_MESSAGE_SUCCESS",
+		},
+		cli.StringSliceFlag{
+			Name:   "message.failure",
+			Usage:  "message template for failed builds",
+			EnvVar: "PLUGIN_MESSAGE_FAILURE,INPUT_MESSAGE_FAILURE",
+		},
+		cli.StringSliceFlag{
+			Name:   "message.killed",
+			Usage:  "message template for killed or cancelled builds",
+			EnvVar: "PLUGIN_MESSAGE_KILLED,INPUT_MESSAGE_KILLED",
+		},
+		cli.StringSliceFlag{
+			Name:   "message.fixed",
+			Usage:  "message template for successful builds after a broken one",
+			EnvVar: "PLUGIN_MESSAGE_FIXED,INPUT_MESSAGE_FIXED",
+		},
+		cli.StringFlag{
+			Name:   "prev.build.status",
+			Usage:  "previous build status",
+			EnvVar: "DRONE_PREV_BUILD_STATUS",
+		},
+		cli.StringFlag{
+			Name:   "rules",
+			Usage:  "notification rules in yaml",
+			EnvVar: "PLUGIN_RULES,INPUT_RULES",
+		},
+		cli.StringFlag{
+			Name:   "rules.file",
+			Usage:  "yaml file with notification rules, relative to the workspace",
+			EnvVar: "PLUGIN_RULES_FILE,INPUT_RULES_FILE",
+		},
+		cli.StringFlag{
+			Name:   "recipients.file",
+			Usage:  "yaml or json file with named recipients and groups, relative to the workspace",
+			EnvVar: "PLUGIN_RECIPIENTS_FILE,INPUT_RECIPIENTS_FILE",
+		},
+		cli.BoolFlag{
+			Name:   "author.only",
+			Usage:  "only notify the commit author and co-authors",
+			EnvVar: "PLUGIN_AUTHOR_ONLY,INPUT_AUTHOR_ONLY",
+		},
+		cli.StringSliceFlag{
+			Name:   "author.fallback",
+			Usage:  "recipients notified when no commit author is found",
+			EnvVar: "PLUGIN_AUTHOR_FALLBACK,INPUT_AUTHOR_FALLBACK",
+		},
+		cli.BoolFlag{
+			Name:   "to.strict",
+			Usage:  "fail on malformed to entries instead of logging a warning",
+			EnvVar: "PLUGIN_TO_STRICT,INPUT_TO_STRICT",
+		},
+		cli.StringFlag{
+			Name:   "format",
+			Usage:  "message format: text, button or card",
+			EnvVar: "PLUGIN_FORMAT,INPUT_FORMAT",
+			Value:  FormatText,
+		},
+		cli.StringFlag{
+			Name:   "carousel",
+			Usage:  "generic template carousel elements in yaml",
+			EnvVar: "PLUGIN_CAROUSEL,INPUT_CAROUSEL",
+		},
+		cli.BoolFlag{
+			Name:   "quick.replies",
+			Usage:  "attach rebuild, ack and mute quick replies to failure notifications",
+			EnvVar: "PLUGIN_QUICK_REPLIES,INPUT_QUICK_REPLIES",
+		},
+		cli.StringFlag{
+			Name:   "store",
+			Usage:  "json file keeping the chat state of the webhook server",
+			EnvVar: "PLUGIN_STORE,FACEBOOK_STORE,INPUT_STORE",
+		},
+		cli.BoolFlag{
+			Name:   "reuse.attachments",
+			Usage:  "upload attachments once and send them to every recipient by attachment id",
+			EnvVar: "PLUGIN_REUSE_ATTACHMENTS,INPUT_REUSE_ATTACHMENTS",
+		},
+		cli.StringFlag{
+			Name:   "attachment.cache",
+			Usage:  "json file caching attachment ids by content hash across builds, relative to the workspace, keep it out of the checkout",
+			EnvVar: "PLUGIN_ATTACHMENT_CACHE,INPUT_ATTACHMENT_CACHE",
+		},
+		cli.StringFlag{
+			Name:   "long.message",
+			Usage:  "how to send messages over the 2000 characters limit: split or truncate",
+			EnvVar: "PLUGIN_LONG_MESSAGE,INPUT_LONG_MESSAGE",
+			Value:  LongMessageSplit,
+		},
+		cli.BoolFlag{
+			Name:   "number.parts",
+			Usage:  "number the parts of split messages, like (1/3)",
+			EnvVar: "PLUGIN_NUMBER_PARTS,INPUT_NUMBER_PARTS",
+		},
+		cli.BoolFlag{
+			Name:   "typing",
+			Usage:  "show the typing indicator while sending notifications and webhook replies",
+			EnvVar: "PLUGIN_TYPING,INPUT_TYPING",
+		},
+		cli.DurationFlag{
+			Name:   "part.delay",
+			Usage:  "delay between the messages of a notification",
+			EnvVar: "PLUGIN_PART_DELAY,INPUT_PART_DELAY",
+		},
+		cli.StringFlag{
+			Name:   "messaging.type",
+			Usage:  "messaging type: RESPONSE, UPDATE or MESSAGE_TAG",
+			EnvVar: "PLUGIN_MESSAGING_TYPE,INPUT_MESSAGING_TYPE",
+			Value:  "RESPONSE",
+		},
+		cli.StringFlag{
+			Name:   "tag",
+			Usage:  "message tag of the MESSAGE_TAG messaging type, like CONFIRMED_EVENT_UPDATE or ACCOUNT_UPDATE",
+			EnvVar: "PLUGIN_TAG,INPUT_TAG",
+		},
+		cli.StringFlag{
+			Name:   "notification.type",
+			Usage:  "push notification type: REGULAR, SILENT_PUSH or NO_PUSH",
+			EnvVar: "PLUGIN_NOTIFICATION_TYPE,INPUT_NOTIFICATION_TYPE",
+		},
+		cli.StringFlag{
+			Name:   "notification.success",
+			Usage:  "push notification type of successful builds",
+			EnvVar: "PLUGIN_NOTIFICATION_SUCCESS,INPUT_NOTIFICATION_SUCCESS",
+		},
+		cli.StringFlag{
+			Name:   "notification.failure",
+			Usage:  "push notification type of failed builds",
+			EnvVar: "PLUGIN_NOTIFICATION_FAILURE,INPUT_NOTIFICATION_FAILURE",
+		},
+		cli.StringFlag{
+			Name:   "notification.killed",
+			Usage:  "push notification type of killed builds",
+			EnvVar: "PLUGIN_NOTIFICATION_KILLED,INPUT_NOTIFICATION_KILLED",
+		},
+		cli.StringFlag{
+			Name:   "persona.id",
+			Usage:  "persona id to send the messages under",
+			EnvVar: "PLUGIN_PERSONA_ID,INPUT_PERSONA_ID",
+		},
+		cli.StringFlag{
+			Name:   "persona.name",
+			Usage:  "persona name to send the messages under, looked up when persona.id is empty",
+			EnvVar: "PLUGIN_PERSONA_NAME,INPUT_PERSONA_NAME",
+		},
+		cli.StringFlag{
+			Name:   "drone.server",
+			Usage:  "drone server url the webhook commands query, enables the rebuild quick reply",
+			EnvVar: "PLUGIN_DRONE_SERVER,DRONE_SERVER",
+		},
+		cli.StringFlag{
+			Name:   "drone.token",
+			Usage:  "drone server api token",
+			EnvVar: "PLUGIN_DRONE_TOKEN,DRONE_TOKEN",
+		},
+		cli.StringSliceFlag{
+			Name:   "admins",
+			Usage:  "facebook user ids allowed to restart and promote builds from chat",
+			EnvVar: "PLUGIN_ADMINS,FACEBOOK_ADMINS",
+		},
+		cli.StringSliceFlag{
+			Name:   "viewers",
+			Usage:  "facebook user ids allowed to query builds from chat, besides the admins",
+			EnvVar: "PLUGIN_VIEWERS,FACEBOOK_VIEWERS",
+		},
+		cli.StringFlag{
+			Name:   "audit.log",
+			Usage:  "file the restart and promote commands are appended to as json lines",
+			EnvVar: "PLUGIN_AUDIT_LOG,FACEBOOK_AUDIT_LOG",
+		},
+		cli.StringSliceFlag{
+			Name:   "subscribe.repos",
+			Usage:  "repositories users may subscribe to, as owner/repo patterns like appleboy/*",
+			EnvVar: "PLUGIN_SUBSCRIBE_REPOS,FACEBOOK_SUBSCRIBE_REPOS",
+		},
+		cli.StringSliceFlag{
+			Name:   "subscribe.users",
+			Usage:  "facebook user ids allowed to subscribe, every user when empty",
+			EnvVar: "PLUGIN_SUBSCRIBE_USERS,FACEBOOK_SUBSCRIBE_USERS",
+		},
+		cli.StringSliceFlag{
+			Name:   "postback.actions",
+			Usage:  "chat commands run for postback payloads, like GET_STARTED=help",
+			EnvVar: "PLUGIN_POSTBACK_ACTIONS,FACEBOOK_POSTBACK_ACTIONS",
+		},
+		cli.StringFlag{
+			Name:   "referral.action",
+			Usage:  "chat command run with the ref of m.me links as arguments, like subscribe, none when empty",
+			EnvVar: "PLUGIN_REFERRAL_ACTION,FACEBOOK_REFERRAL_ACTION",
+		},
+		cli.StringFlag{
+			Name:   "optin.action",
+			Usage:  "chat command run with the ref of opt-ins as arguments, like subscribe, none when empty",
+			EnvVar: "PLUGIN_OPTIN_ACTION,FACEBOOK_OPTIN_ACTION",
+		},
 	}
 
 	if err := app.Run(os.Args); err != nil {
@@ -282,6 +524,8 @@ func run(c *cli.Context) error {
 			Finished: c.Float64("job.finished"),
 			PR:       c.String("pull.request"),
 			DeployTo: c.String("deploy.to"),
+
+			PrevStatus: c.String("prev.build.status"),
 		},
 		Config: Config{
 			PageToken:   c.String("page.token"),
@@ -299,6 +543,54 @@ func run(c *cli.Context) error {
 			Host:        c.StringSlice("host"),
 			AppSecret:   c.String("app.secret"),
 			GitHub:      c.Bool("github"),
+
+			FailurePolicy:    c.String("failure.policy"),
+			RetryAttempts:    c.Int("retry.attempts"),
+			RetryMaxElapsed:  c.Duration("retry.max.elapsed"),
+			Parallelism:      c.Int("parallelism"),
+			RateLimit:        c.Float64("rate.limit"),
+			DryRun:           c.Bool("dry.run"),
+			TemplateFile:     c.StringSlice("template.file"),
+			Workspace:        c.String("workspace"),
+			MessageSuccess:   c.StringSlice("message.success"),
+			MessageFailure:   c.StringSlice("message.failure"),
+			MessageKilled:    c.StringSlice("message.killed"),
+			MessageFixed:     c.StringSlice("message.fixed"),
+			Rules:            c.String("rules"),
+			RulesFile:        c.String("rules.file"),
+			RecipientsFile:   c.String("recipients.file"),
+			AuthorOnly:       c.Bool("author.only"),
+			AuthorFallback:   c.StringSlice("author.fallback"),
+			StrictTo:         c.Bool("to.strict"),
+			Format:           c.String("format"),
+			Carousel:         c.String("carousel"),
+			QuickReplies:     c.Bool("quick.replies"),
+			StorePath:        c.String("store"),
+			ReuseAttachments: c.Bool("reuse.attachments"),
+			AttachmentCache:  c.String("attachment.cache"),
+			LongMessage:      c.String("long.message"),
+			NumberParts:      c.Bool("number.parts"),
+			Typing:           c.Bool("typing"),
+			PartDelay:        c.Duration("part.delay"),
+			MessagingType:    c.String("messaging.type"),
+			Tag:              c.String("tag"),
+
+			NotificationType:    c.String("notification.type"),
+			NotificationSuccess: c.String("notification.success"),
+			NotificationFailure: c.String("notification.failure"),
+			NotificationKilled:  c.String("notification.killed"),
+			PersonaID:           c.String("persona.id"),
+			PersonaName:         c.String("persona.name"),
+			DroneServer:         c.String("drone.server"),
+			DroneToken:          c.String("drone.token"),
+			Admins:              c.StringSlice("admins"),
+			Viewers:             c.StringSlice("viewers"),
+			AuditLog:            c.String("audit.log"),
+			PostbackActions:     c.StringSlice("postback.actions"),
+			SubscribeRepos:      c.StringSlice("subscribe.repos"),
+			SubscribeUsers:      c.StringSlice("subscribe.users"),
+			ReferralAction:      c.String("referral.action"),
+			OptInAction:         c.String("optin.action"),
 		},
 	}
 
@@ -308,5 +600,9 @@ func run(c *cli.Context) error {
 		return plugin.Webhook()
 	}
 
+	if command == "persona" {
+		return plugin.Personas(c.Args().Tail(), os.Stdout)
+	}
+
 	return plugin.Exec()
 }
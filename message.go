@@ -0,0 +1,342 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/paked/messenger"
+)
+
+// Message formats.
+const (
+	// FormatText sends the rendered message as plain text.
+	FormatText = "text"
+	// FormatButton sends a button template with links to the build.
+	FormatButton = "button"
+	// FormatCard sends a generic template card with links to the build.
+	FormatCard = "card"
+)
+
+// Message tags allowed with the MESSAGE_TAG messaging type.
+var messageTags = []string{
+	"CONFIRMED_EVENT_UPDATE",
+	"POST_PURCHASE_UPDATE",
+	"ACCOUNT_UPDATE",
+	"HUMAN_AGENT",
+}
+
+// Push notification types.
+const (
+	NotificationRegular = "REGULAR"
+	NotificationSilent  = "SILENT_PUSH"
+	NotificationNoPush  = "NO_PUSH"
+)
+
+// Sender actions.
+const (
+	MarkSeen  = "mark_seen"
+	TypingOn  = "typing_on"
+	TypingOff = "typing_off"
+)
+
+type (
+	// sendRequest is a Send API request body.
+	sendRequest struct {
+		MessagingType    messenger.MessagingType `json:"messaging_type,omitempty"`
+		Recipient        messenger.Recipient     `json:"recipient"`
+		Message          *messageData            `json:"message,omitempty"`
+		Tag              string                  `json:"tag,omitempty"`
+		SenderAction     string                  `json:"sender_action,omitempty"`
+		NotificationType string                  `json:"notification_type,omitempty"`
+		PersonaID        string                  `json:"persona_id,omitempty"`
+
+		// File is a local file uploaded as the attachment.
+		File string `json:"-"`
+	}
+
+	messageData struct {
+		Text         string                 `json:"text,omitempty"`
+		Attachment   *attachmentData        `json:"attachment,omitempty"`
+		QuickReplies []messenger.QuickReply `json:"quick_replies,omitempty"`
+	}
+
+	attachmentData struct {
+		Type    string      `json:"type"`
+		Payload payloadData `json:"payload"`
+	}
+
+	payloadData struct {
+		URL          string        `json:"url,omitempty"`
+		IsReusable   bool          `json:"is_reusable,omitempty"`
+		AttachmentID string        `json:"attachment_id,omitempty"`
+		TemplateType string        `json:"template_type,omitempty"`
+		Text         string        `json:"text,omitempty"`
+		Elements     []elementData `json:"elements,omitempty"`
+		Buttons      []buttonData  `json:"buttons,omitempty"`
+	}
+
+	elementData struct {
+		Title         string             `json:"title"`
+		Subtitle      string             `json:"subtitle,omitempty"`
+		ImageURL      string             `json:"image_url,omitempty"`
+		DefaultAction *defaultActionData `json:"default_action,omitempty"`
+		Buttons       []buttonData       `json:"buttons,omitempty"`
+	}
+
+	defaultActionData struct {
+		Type string `json:"type"`
+		URL  string `json:"url"`
+	}
+
+	// part is a single message of a notification.
+	part struct {
+		kind    string
+		message *messageData
+		file    string
+		// reused is set when the part sends a cached attachment id.
+		reused *reusedAttachment
+	}
+
+	buttonData struct {
+		Type    string `json:"type"`
+		Title   string `json:"title"`
+		URL     string `json:"url,omitempty"`
+		Payload string `json:"payload,omitempty"`
+	}
+)
+
+// Send API limits of the template fields.
+const (
+	maxButtonText      = 640
+	maxElementTitle    = 80
+	maxElementSubtitle = 80
+)
+
+// request wraps a message into a Send API request for the recipient.
+func (p Plugin) request(id int64, m *messageData) *sendRequest {
+	req := &sendRequest{
+		MessagingType: messenger.ResponseType,
+		Recipient:     messenger.Recipient{ID: id},
+		Message:       m,
+	}
+
+	if p.Config.MessagingType != "" {
+		req.MessagingType = messenger.MessagingType(p.Config.MessagingType)
+	}
+	if req.MessagingType == messenger.MessageTagType {
+		req.Tag = p.Config.Tag
+	}
+	req.NotificationType = p.notificationType()
+	req.PersonaID = p.Config.PersonaID
+
+	return req
+}
+
+// notificationType returns the push notification type of the build status,
+// falling back to the notification_type setting.
+func (p Plugin) notificationType() string {
+	var value string
+	switch p.Build.Status {
+	case "success":
+		value = p.Config.NotificationSuccess
+	case "failure", "error":
+		value = p.Config.NotificationFailure
+	case "killed", "cancelled":
+		value = p.Config.NotificationKilled
+	}
+
+	if value == "" {
+		return p.Config.NotificationType
+	}
+
+	return value
+}
+
+func checkNotificationType(values ...string) error {
+	for _, value := range values {
+		switch value {
+		case "", NotificationRegular, NotificationSilent, NotificationNoPush:
+		default:
+			return fmt.Errorf("unknown notification type: %s", value)
+		}
+	}
+
+	return nil
+}
+
+// senderAction is a request showing a sender action, such as the typing
+// indicator, instead of sending a message.
+func senderAction(id int64, action string) *sendRequest {
+	return &sendRequest{
+		Recipient:    messenger.Recipient{ID: id},
+		SenderAction: action,
+	}
+}
+
+func textMessage(text string) *messageData {
+	return &messageData{
+		Text: text,
+	}
+}
+
+func attachmentMessage(dataType messenger.AttachmentType, url string) *messageData {
+	return &messageData{
+		Attachment: &attachmentData{
+			Type: string(dataType),
+			Payload: payloadData{
+				URL: url,
+			},
+		},
+	}
+}
+
+// uploadMessage is an attachment whose content is uploaded with the request.
+func uploadMessage(dataType messenger.AttachmentType) *messageData {
+	return &messageData{
+		Attachment: &attachmentData{
+			Type: string(dataType),
+		},
+	}
+}
+
+// reusedMessage is an attachment sent by the ID of an earlier upload.
+func reusedMessage(dataType string, id string) *messageData {
+	return &messageData{
+		Attachment: &attachmentData{
+			Type: dataType,
+			Payload: payloadData{
+				AttachmentID: id,
+			},
+		},
+	}
+}
+
+func buttonMessage(text string, buttons []buttonData) *messageData {
+	return &messageData{
+		Attachment: &attachmentData{
+			Type: "template",
+			Payload: payloadData{
+				TemplateType: "button",
+				Text:         truncate(text, maxButtonText),
+				Buttons:      buttons,
+			},
+		},
+	}
+}
+
+func genericMessage(elements []elementData) *messageData {
+	return &messageData{
+		Attachment: &attachmentData{
+			Type: "template",
+			Payload: payloadData{
+				TemplateType: "generic",
+				Elements:     elements,
+			},
+		},
+	}
+}
+
+func checkFormat(format string) error {
+	switch format {
+	case "", FormatText, FormatButton, FormatCard:
+		return nil
+	}
+
+	return fmt.Errorf("unknown message format: %s", format)
+}
+
+// checkMessaging validates the messaging type and tag combination.
+func checkMessaging(messagingType, tag string) error {
+	switch messenger.MessagingType(messagingType) {
+	case "", messenger.ResponseType, messenger.UpdateType:
+		if tag != "" {
+			return fmt.Errorf("tag %s needs the %s messaging type", tag, messenger.MessageTagType)
+		}
+		return nil
+	case messenger.MessageTagType:
+		if tag == "" {
+			return fmt.Errorf("the %s messaging type needs a tag: %s", messagingType, strings.Join(messageTags, ", "))
+		}
+		for _, t := range messageTags {
+			if t == tag {
+				return nil
+			}
+		}
+		return fmt.Errorf("unknown message tag: %s", tag)
+	}
+
+	return fmt.Errorf("unknown messaging type: %s", messagingType)
+}
+
+// textNotification formats a rendered message for the format setting,
+// falling back to plain text when there is no link to attach.
+func (p Plugin) textNotification(text string) (string, *messageData) {
+	if p.Config.Format == "" || p.Config.Format == FormatText {
+		return "text", textMessage(text)
+	}
+
+	buttons := p.linkButtons()
+	if len(buttons) == 0 {
+		return "text", textMessage(text)
+	}
+
+	switch p.Config.Format {
+	case FormatButton:
+		return "button template", buttonMessage(text, buttons)
+	case FormatCard:
+		title, subtitle := text, ""
+		if i := strings.Index(text, "\n"); i >= 0 {
+			title, subtitle = text[:i], strings.TrimSpace(text[i+1:])
+		}
+		element := elementData{
+			Title:    truncate(title, maxElementTitle),
+			Subtitle: truncate(subtitle, maxElementSubtitle),
+			Buttons:  buttons,
+		}
+		if p.Build.Link != "" {
+			element.DefaultAction = &defaultActionData{Type: "web_url", URL: p.Build.Link}
+		}
+		return "card", genericMessage([]elementData{element})
+	}
+
+	return "text", textMessage(text)
+}
+
+// linkButtons returns URL buttons to the build, the commit and the pull request.
+func (p Plugin) linkButtons() []buttonData {
+	var buttons []buttonData
+
+	if p.Build.Link != "" {
+		buttons = append(buttons, urlButton("View build", p.Build.Link))
+	}
+	// drone sets the commit link of pull request builds to the pull
+	// request page, whatever the layout of the git host.
+	if p.Commit.Link != "" {
+		title := "View commit"
+		if p.Build.PR != "" {
+			title = "View pull request"
+		}
+		buttons = append(buttons, urlButton(title, p.Commit.Link))
+	}
+
+	return buttons
+}
+
+func urlButton(title, url string) buttonData {
+	return buttonData{
+		Type:  "web_url",
+		Title: title,
+		URL:   url,
+	}
+}
+
+// truncate shortens s to at most max runes, ending with an ellipsis.
+func truncate(s string, max int) string {
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+
+	runes := []rune(s)
+	return string(runes[:max-1]) + "…"
+}
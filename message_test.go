@@ -0,0 +1,101 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTextNotification(t *testing.T) {
+	plugin := Plugin{
+		Commit: Commit{
+			Link: "https://github.com/appleboy/go-hello/commit/e7c4f0a",
+		},
+		Build: Build{
+			Link: "https://cloud.drone.io/appleboy/go-hello/101",
+		},
+	}
+
+	kind, m := plugin.textNotification("build 101 failed")
+	assert.Equal(t, "text", kind)
+	assert.Equal(t, textMessage("build 101 failed"), m)
+
+	plugin.Config.Format = FormatButton
+	kind, m = plugin.textNotification("build 101 failed")
+	assert.Equal(t, "button template", kind)
+	assert.Equal(t, "button", m.Attachment.Payload.TemplateType)
+	assert.Equal(t, "build 101 failed", m.Attachment.Payload.Text)
+	assert.Equal(t, []buttonData{
+		{Type: "web_url", Title: "View build", URL: "https://cloud.drone.io/appleboy/go-hello/101"},
+		{Type: "web_url", Title: "View commit", URL: "https://github.com/appleboy/go-hello/commit/e7c4f0a"},
+	}, m.Attachment.Payload.Buttons)
+
+	// the commit link of pull request builds is the pull request page
+	pr := plugin
+	pr.Build.PR = "12"
+	pr.Commit.Link = "https://gitea.example.com/appleboy/go-hello/pulls/12"
+	_, m = pr.textNotification("build 101 failed")
+	assert.Equal(t, buttonData{Type: "web_url", Title: "View pull request", URL: "https://gitea.example.com/appleboy/go-hello/pulls/12"}, m.Attachment.Payload.Buttons[1])
+
+	plugin.Config.Format = FormatCard
+	kind, m = plugin.textNotification("build 101 failed\n\nupdate travis by drone plugin")
+	assert.Equal(t, "card", kind)
+	element := m.Attachment.Payload.Elements[0]
+	assert.Equal(t, "build 101 failed", element.Title)
+	assert.Equal(t, "update travis by drone plugin", element.Subtitle)
+	assert.Equal(t, "https://cloud.drone.io/appleboy/go-hello/101", element.DefaultAction.URL)
+	assert.Len(t, element.Buttons, 2)
+
+	// nothing to link to
+	plugin = Plugin{Config: Config{Format: FormatButton}}
+	kind, _ = plugin.textNotification("build 101 failed")
+	assert.Equal(t, "text", kind)
+}
+
+func TestTruncate(t *testing.T) {
+	assert.Equal(t, "hello", truncate("hello", 5))
+	assert.Equal(t, "hell…", truncate("hello!", 5))
+	assert.Equal(t, "建置失…", truncate("建置失敗了", 4))
+}
+
+func TestMessagingType(t *testing.T) {
+	assert.NoError(t, checkMessaging("", ""))
+	assert.NoError(t, checkMessaging("UPDATE", ""))
+	assert.NoError(t, checkMessaging("MESSAGE_TAG", "ACCOUNT_UPDATE"))
+	assert.EqualError(t, checkMessaging("RESPONSE", "ACCOUNT_UPDATE"), "tag ACCOUNT_UPDATE needs the MESSAGE_TAG messaging type")
+	assert.EqualError(t, checkMessaging("MESSAGE_TAG", "GAME_EVENT"), "unknown message tag: GAME_EVENT")
+	assert.EqualError(t, checkMessaging("PROMOTION", ""), "unknown messaging type: PROMOTION")
+	assert.Error(t, checkMessaging("MESSAGE_TAG", ""))
+
+	req := Plugin{}.request(1, textMessage("hi"))
+	assert.Equal(t, "RESPONSE", string(req.MessagingType))
+	assert.Empty(t, req.Tag)
+
+	req = Plugin{Config: Config{MessagingType: "MESSAGE_TAG", Tag: "CONFIRMED_EVENT_UPDATE"}}.request(1, textMessage("hi"))
+	assert.Equal(t, "MESSAGE_TAG", string(req.MessagingType))
+	assert.Equal(t, "CONFIRMED_EVENT_UPDATE", req.Tag)
+
+	err := &GraphError{Message: "(#10) This message is sent outside of allowed window.", Code: 10, Subcode: 2018278}
+	assert.True(t, err.OutsideWindow())
+	assert.Contains(t, err.Error(), "has not messaged the page in the last 24 hours")
+}
+
+func TestNotificationType(t *testing.T) {
+	plugin := Plugin{
+		Config: Config{
+			NotificationType:    NotificationRegular,
+			NotificationSuccess: NotificationSilent,
+		},
+	}
+
+	plugin.Build.Status = "success"
+	assert.Equal(t, NotificationSilent, plugin.request(1, textMessage("hi")).NotificationType)
+
+	plugin.Build.Status = "failure"
+	assert.Equal(t, NotificationRegular, plugin.request(1, textMessage("hi")).NotificationType)
+
+	assert.Empty(t, Plugin{}.request(1, textMessage("hi")).NotificationType)
+
+	assert.NoError(t, checkNotificationType("", NotificationNoPush))
+	assert.EqualError(t, checkNotificationType(NotificationRegular, "LOUD"), "unknown notification type: LOUD")
+}
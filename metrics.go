@@ -9,8 +9,11 @@ const namespace = "facebook_"
 // Metrics implements the prometheus.Metrics interface and
 // exposes gitea metrics for prometheus
 type Metrics struct {
-	ReceiveCount *prometheus.Desc
-	SendCount    *prometheus.Desc
+	ReceiveCount  *prometheus.Desc
+	SendCount     *prometheus.Desc
+	PostbackCount *prometheus.Desc
+	ReferralCount *prometheus.Desc
+	OptInCount    *prometheus.Desc
 }
 
 // NewMetrics returns a new Metrics with all prometheus.Desc initialized
@@ -27,6 +30,21 @@ func NewMetrics() Metrics {
 			"Number of send count",
 			nil, nil,
 		),
+		PostbackCount: prometheus.NewDesc(
+			namespace+"postback_count",
+			"Number of postback count",
+			nil, nil,
+		),
+		ReferralCount: prometheus.NewDesc(
+			namespace+"referral_count",
+			"Number of referral count",
+			nil, nil,
+		),
+		OptInCount: prometheus.NewDesc(
+			namespace+"optin_count",
+			"Number of opt-in count",
+			nil, nil,
+		),
 	}
 }
 
@@ -34,6 +52,9 @@ func NewMetrics() Metrics {
 func (c Metrics) Describe(ch chan<- *prometheus.Desc) {
 	ch <- c.ReceiveCount
 	ch <- c.SendCount
+	ch <- c.PostbackCount
+	ch <- c.ReferralCount
+	ch <- c.OptInCount
 }
 
 // Collect returns the metrics with values
@@ -48,4 +69,19 @@ func (c Metrics) Collect(ch chan<- prometheus.Metric) {
 		prometheus.GaugeValue,
 		float64(SendCount),
 	)
+	ch <- prometheus.MustNewConstMetric(
+		c.PostbackCount,
+		prometheus.GaugeValue,
+		float64(PostbackCount),
+	)
+	ch <- prometheus.MustNewConstMetric(
+		c.ReferralCount,
+		prometheus.GaugeValue,
+		float64(ReferralCount),
+	)
+	ch <- prometheus.MustNewConstMetric(
+		c.OptInCount,
+		prometheus.GaugeValue,
+		float64(OptInCount),
+	)
 }
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"text/tabwriter"
+)
+
+// Persona is a bot identity messages can be sent under.
+type Persona struct {
+	ID                string `json:"id"`
+	Name              string `json:"name"`
+	ProfilePictureURL string `json:"profile_picture_url"`
+}
+
+// CreatePersona creates a persona and returns its ID.
+func (g *graphClient) CreatePersona(name, picture string) (string, error) {
+	var result struct {
+		ID string `json:"id"`
+	}
+
+	if err := g.post(g.graphURL+"/me/personas", &Persona{Name: name, ProfilePictureURL: picture}, &result); err != nil {
+		return "", err
+	}
+
+	return result.ID, nil
+}
+
+// Personas lists the personas of the page, following the pagination.
+func (g *graphClient) Personas() ([]Persona, error) {
+	var personas []Persona
+
+	next := g.graphURL + "/me/personas"
+	for next != "" {
+		var result struct {
+			Data   []Persona `json:"data"`
+			Paging struct {
+				Next string `json:"next"`
+			} `json:"paging"`
+		}
+
+		if err := g.do("GET", next, nil, "", &result); err != nil {
+			return nil, err
+		}
+
+		personas = append(personas, result.Data...)
+		next = result.Paging.Next
+	}
+
+	return personas, nil
+}
+
+// DeletePersona deletes the persona with the ID.
+func (g *graphClient) DeletePersona(id string) error {
+	return g.do("DELETE", g.graphURL+"/"+url.PathEscape(id), nil, "", nil)
+}
+
+// resolvePersona returns the persona_id setting, or looks up the ID of
+// the persona_name setting.
+func (p Plugin) resolvePersona(g *graphClient) (string, error) {
+	if p.Config.PersonaID != "" || p.Config.PersonaName == "" {
+		return p.Config.PersonaID, nil
+	}
+
+	personas, err := g.Personas()
+	if err != nil {
+		return "", err
+	}
+
+	for _, persona := range personas {
+		if persona.Name == p.Config.PersonaName {
+			return persona.ID, nil
+		}
+	}
+
+	return "", fmt.Errorf("unknown persona: %s", p.Config.PersonaName)
+}
+
+// Personas runs the persona command: create <name> <picture url>, list
+// or delete <id>.
+func (p Plugin) Personas(args []string, out io.Writer) error {
+	if len(p.Config.PageToken) == 0 {
+		return errors.New("missing facebook config")
+	}
+
+	client := newGraphClient(p.Config.PageToken)
+	return p.personas(client, args, out)
+}
+
+func (p Plugin) personas(client *graphClient, args []string, out io.Writer) error {
+	usage := errors.New("usage: persona create <name> <picture url> | persona list | persona delete <id>")
+	if len(args) == 0 {
+		return usage
+	}
+
+	switch args[0] {
+	case "create":
+		if len(args) != 3 {
+			return usage
+		}
+		id, err := client.CreatePersona(args[1], args[2])
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(out, id)
+		return err
+	case "list":
+		personas, err := client.Personas()
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tPICTURE")
+		for _, persona := range personas {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", persona.ID, persona.Name, persona.ProfilePictureURL)
+		}
+		return w.Flush()
+	case "delete":
+		if len(args) != 2 {
+			return usage
+		}
+		return client.DeletePersona(args[1])
+	}
+
+	return usage
+}
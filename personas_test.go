@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPersonas(t *testing.T) {
+	var deleted []string
+
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "token", r.URL.Query().Get("access_token"))
+
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/me/personas":
+			var persona Persona
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&persona))
+			assert.Equal(t, Persona{Name: "Drone CI", ProfilePictureURL: "https://example.com/drone.png"}, persona)
+			fmt.Fprint(w, `{"id":"1001"}`)
+		case r.Method == "GET" && r.URL.Path == "/me/personas" && r.URL.Query().Get("after") == "":
+			fmt.Fprintf(w, `{"data":[{"id":"1001","name":"Drone CI","profile_picture_url":"https://example.com/drone.png"}],"paging":{"next":"%s/me/personas?after=abc&access_token=token"}}`, ts.URL)
+		case r.Method == "GET" && r.URL.Path == "/me/personas":
+			fmt.Fprint(w, `{"data":[{"id":"1002","name":"Release bot"}],"paging":{}}`)
+		case r.Method == "DELETE":
+			deleted = append(deleted, r.URL.Path)
+			fmt.Fprint(w, `{"success":true}`)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+	}))
+	defer ts.Close()
+
+	client := newGraphClient("token")
+	client.graphURL = ts.URL
+
+	var out bytes.Buffer
+	plugin := Plugin{}
+	assert.NoError(t, plugin.personas(client, []string{"create", "Drone CI", "https://example.com/drone.png"}, &out))
+	assert.Equal(t, "1001\n", out.String())
+
+	out.Reset()
+	assert.NoError(t, plugin.personas(client, []string{"list"}, &out))
+	assert.Equal(t, "ID    NAME         PICTURE\n"+
+		"1001  Drone CI     https://example.com/drone.png\n"+
+		"1002  Release bot  \n", out.String())
+
+	assert.NoError(t, plugin.personas(client, []string{"delete", "1002"}, &out))
+	assert.Equal(t, []string{"/1002"}, deleted)
+
+	assert.Error(t, plugin.personas(client, []string{"delete"}, &out))
+
+	// persona names are resolved to ids
+	plugin.Config.PersonaName = "Release bot"
+	id, err := plugin.resolvePersona(client)
+	assert.NoError(t, err)
+	assert.Equal(t, "1002", id)
+
+	plugin.Config.PersonaName = "Nobody"
+	_, err = plugin.resolvePersona(client)
+	assert.EqualError(t, err, "unknown persona: Nobody")
+
+	plugin.Config.PersonaID = "1001"
+	id, err = plugin.resolvePersona(client)
+	assert.NoError(t, err)
+	assert.Equal(t, "1001", id)
+	assert.Equal(t, "1001", plugin.request(1, textMessage("hi")).PersonaID)
+}
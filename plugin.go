@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/drone/drone-template-lib/template"
@@ -56,6 +58,8 @@ type (
 		Finished float64
 		PR       string
 		DeployTo string
+
+		PrevStatus string
 	}
 
 	// Config for the plugin.
@@ -75,6 +79,54 @@ type (
 		Host        []string
 		GitHub      bool
 		AppSecret   string
+
+		FailurePolicy    string
+		RetryAttempts    int
+		RetryMaxElapsed  time.Duration
+		Parallelism      int
+		RateLimit        float64
+		DryRun           bool
+		TemplateFile     []string
+		Workspace        string
+		MessageSuccess   []string
+		MessageFailure   []string
+		MessageKilled    []string
+		MessageFixed     []string
+		Rules            string
+		RulesFile        string
+		RecipientsFile   string
+		AuthorOnly       bool
+		AuthorFallback   []string
+		StrictTo         bool
+		Format           string
+		Carousel         string
+		QuickReplies     bool
+		StorePath        string
+		ReuseAttachments bool
+		AttachmentCache  string
+		LongMessage      string
+		NumberParts      bool
+		Typing           bool
+		PartDelay        time.Duration
+		MessagingType    string
+		Tag              string
+
+		NotificationType    string
+		NotificationSuccess string
+		NotificationFailure string
+		NotificationKilled  string
+		PersonaID           string
+		PersonaName         string
+		DroneServer         string
+		DroneToken          string
+		Admins              []string
+		Viewers             []string
+		AuditLog            string
+		PostbackActions     []string
+		SubscribeRepos      []string
+		SubscribeUsers      []string
+		ReferralAction      string
+		OptInAction         string
 	}
 
 	// Plugin values.
@@ -92,6 +144,12 @@ var (
 	ReceiveCount int64
 	// SendCount is send notification count
 	SendCount int64
+	// PostbackCount is postback count
+	PostbackCount int64
+	// ReferralCount is referral count
+	ReferralCount int64
+	// OptInCount is opt-in count
+	OptInCount int64
 )
 
 func init() {
@@ -155,18 +213,94 @@ func parseTo(to []string, authorEmail string, matchEmail bool) []int64 {
 
 // Handler is http handler.
 func (p Plugin) Handler(client *messenger.Messenger) http.Handler {
-	// Setup a handler to be triggered when a message is received
-	client.HandleMessage(func(m messenger.Message, r *messenger.Response) {
-		fmt.Printf("%v (Sent, %v)\n", m.Text, m.Time.Format(time.UnixDate))
+	store, err := p.Store()
+	if err != nil {
+		log.Println("error to open the store:", err)
+	}
+
+	router := p.Router()
 
-		p, err := client.ProfileByID(m.Sender.ID, []string{"name", "first_name", "last_name", "profile_pic"})
+	// newContext looks up the name of the user for the commands and the
+	// audit log.
+	newContext := func(user int64) *Context {
+		profile, err := client.ProfileByID(user, []string{"name", "first_name", "last_name", "profile_pic"})
 		if err != nil {
 			log.Println("Something went wrong!", err)
 		}
 
+		return &Context{
+			User:  user,
+			Name:  profile.FirstName,
+			Store: store,
+		}
+	}
+
+	// Setup a handler to be triggered when a message is received
+	client.HandleMessage(func(m messenger.Message, r *messenger.Response) {
+		fmt.Printf("%v (Sent, %v)\n", m.Text, m.Time.Format(time.UnixDate))
+
+		ctx := newContext(m.Sender.ID)
+
 		ReceiveCount++
-		if err := r.Text(fmt.Sprintf("Hello, %v!", p.FirstName), messenger.ResponseType); err != nil {
-			log.Println("Something went wrong!", err)
+
+		// show the message was read while the reply is prepared
+		if p.Config.Typing {
+			for _, action := range []string{MarkSeen, TypingOn} {
+				if err := r.SenderAction(action); err != nil {
+					log.Println("Something went wrong!", err)
+				}
+			}
+		}
+
+		var reply string
+		if action, ok := quickReplyAction(m); ok {
+			// quick replies of notifications and confirmations
+			reply = p.handleAction(ctx, action)
+		} else {
+			reply = router.Handle(ctx, m.Text)
+		}
+
+		respond(r, ctx, reply)
+	})
+
+	// Setup a handler to be triggered when a template button is tapped
+	client.HandlePostBack(func(pb messenger.PostBack, r *messenger.Response) {
+		PostbackCount++
+
+		ctx := newContext(pb.Sender.ID)
+
+		// the get started button of m.me links carries the referral
+		if reply, ok := handleRef(ctx, router, p.Config.ReferralAction, pb.Referral.Ref); ok {
+			ReferralCount++
+			respond(r, ctx, reply)
+			return
+		}
+
+		respond(r, ctx, p.handlePostBack(ctx, router, pb.Payload))
+	})
+
+	// Setup a handler to be triggered when an m.me link is opened in an
+	// existing conversation
+	client.HandleReferral(func(ref messenger.ReferralMessage, r *messenger.Response) {
+		if ref.Referral == nil {
+			return
+		}
+
+		ReferralCount++
+
+		ctx := newContext(ref.Sender.ID)
+		if reply, ok := handleRef(ctx, router, p.Config.ReferralAction, ref.Ref); ok {
+			respond(r, ctx, reply)
+		}
+	})
+
+	// Setup a handler to be triggered when a user opts in through a plugin
+	client.HandleOptIn(func(o messenger.OptIn, r *messenger.Response) {
+		OptInCount++
+
+		ctx := newContext(o.Sender.ID)
+		if reply, ok := handleRef(ctx, router, p.Config.OptInAction, o.Ref); ok {
+			respond(r, ctx, reply)
 		}
 	})
 
@@ -184,6 +318,22 @@ func (p Plugin) Handler(client *messenger.Messenger) http.Handler {
 	return client.Handler()
 }
 
+// Router returns the chat commands of the webhook server.
+func (p Plugin) Router() *Router {
+	router := NewRouter()
+	for _, cmd := range p.subscriptionCommands() {
+		router.Register(cmd)
+	}
+	for _, cmd := range p.droneCommands() {
+		router.Register(cmd)
+	}
+	for _, cmd := range p.deployCommands() {
+		router.Register(cmd)
+	}
+
+	return router
+}
+
 // Webhook support line callback service.
 func (p Plugin) Webhook() error {
 	client, err := p.Bot()
@@ -241,75 +391,342 @@ func (p Plugin) Bot() (*messenger.Messenger, error) {
 
 // Exec executes the plugin.
 func (p Plugin) Exec() error {
+	if p.Config.DryRun {
+		return p.send(&dryRunSender{out: os.Stdout}, nil)
+	}
 
-	client, err := p.Bot()
+	if len(p.Config.PageToken) == 0 || len(p.Config.VerifyToken) == 0 {
+		return errors.New("missing facebook config")
+	}
+
+	graph := newGraphClient(p.Config.PageToken)
+
+	personaID, err := p.resolvePersona(graph)
 	if err != nil {
 		return err
 	}
+	p.Config.PersonaID = personaID
 
-	var message []string
-	if len(p.Config.Message) > 0 {
-		message = p.Config.Message
-	} else {
-		message = p.Message()
+	var client sender = graph
+	if p.Config.RateLimit > 0 {
+		client = newRateLimitSender(client, p.Config.RateLimit)
 	}
+	retry := newRetrySender(client, p.Config.RetryAttempts, p.Config.RetryMaxElapsed)
 
-	ids := parseTo(p.Config.To, p.Commit.Email, p.Config.MatchEmail)
+	return p.send(retry, retry)
+}
 
-	// send message.
-	for _, user := range ids {
-		To := messenger.Recipient{
-			ID: user,
+func (p Plugin) send(client sender, up uploader) error {
+	if err := checkPolicy(p.Config.FailurePolicy); err != nil {
+		return err
+	}
+
+	if err := checkFormat(p.Config.Format); err != nil {
+		return err
+	}
+
+	if err := checkLongMessage(p.Config.LongMessage); err != nil {
+		return err
+	}
+
+	if err := checkMessaging(p.Config.MessagingType, p.Config.Tag); err != nil {
+		return err
+	}
+
+	if err := checkNotificationType(
+		p.Config.NotificationType,
+		p.Config.NotificationSuccess,
+		p.Config.NotificationFailure,
+		p.Config.NotificationKilled,
+	); err != nil {
+		return err
+	}
+
+	ids, messages, err := p.plan()
+	if err != nil {
+		return err
+	}
+
+	extras, err := p.extras()
+	if err != nil {
+		return err
+	}
+	if p.Config.ReuseAttachments && up != nil {
+		if extras, err = p.reuseAttachments(up, extras); err != nil {
+			return err
 		}
+	}
 
-		// send text notification
-		for _, value := range trimElement(message) {
-			text, err := template.RenderTrim(value, p)
-			if err != nil {
-				log.Println("error to parse the template:", err)
-				continue
-			}
+	store, err := p.Store()
+	if err != nil {
+		return err
+	}
+	if store != nil {
+		if ids, err = p.subscribers(store, ids, messages); err != nil {
+			return err
+		}
+		ids = store.unmuted(ids, p.Repo.FullName, time.Now())
+	}
 
-			if err := client.Send(To, text, messenger.ResponseType); err != nil {
-				log.Println("error to send the text:", err)
-				continue
+	report := &sendReport{}
+
+	parallelism := p.Config.Parallelism
+	if parallelism < 1 {
+		parallelism = 1
+	}
+
+	// fan out to recipients, a worker sends all messages of one
+	// recipient in order before taking the next one.
+	queue := make(chan int64)
+	var wg sync.WaitGroup
+	for i := 0; i < parallelism; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for user := range queue {
+				p.notify(client, user, messages[user], extras, report)
 			}
+		}()
+	}
+
+	for _, user := range ids {
+		queue <- user
+	}
+	close(queue)
+	wg.Wait()
+
+	return report.check(p.Config.FailurePolicy)
+}
+
+// plan returns the recipients in order and the templates each of them gets.
+func (p Plugin) plan() ([]int64, map[int64][]string, error) {
+	rules, err := p.rules()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dir, err := p.directory()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	message, err := p.defaultMessage()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(rules) == 0 {
+		ids, err := p.recipients(dir)
+		if err != nil {
+			return nil, nil, err
+		}
+		messages := make(map[int64][]string, len(ids))
+		for _, id := range ids {
+			messages[id] = message
 		}
+		return ids, messages, nil
+	}
 
-		// send image notification
-		for _, value := range trimElement(p.Config.Image) {
-			if err := client.Attachment(To, messenger.ImageAttachment, value, messenger.ResponseType); err != nil {
-				log.Println("error to send the image:", err)
-				continue
-			}
+	var ids []int64
+	messages := make(map[int64][]string)
+	for i, rule := range rules {
+		if !rule.Match(p) {
+			continue
 		}
+		log.Printf("rule %s matched\n", rule.label(i))
 
-		// send audio notification
-		for _, value := range trimElement(p.Config.Audio) {
-			if err := client.Attachment(To, messenger.AudioAttachment, value, messenger.ResponseType); err != nil {
-				log.Println("error to send the audio:", err)
-				continue
+		ruleMessage := message
+		if values := trimElement(rule.Message); len(values) > 0 {
+			if ruleMessage, err = p.loadTemplates(values); err != nil {
+				return nil, nil, fmt.Errorf("rule %s: %v", rule.label(i), err)
 			}
 		}
 
-		// send video notification
-		for _, value := range trimElement(p.Config.Video) {
-			if err := client.Attachment(To, messenger.VideoAttachment, value, messenger.ResponseType); err != nil {
-				log.Println("error to send the video:", err)
-				continue
+		var ruleIDs []int64
+		if len(rule.To) > 0 {
+			ruleIDs, err = p.resolveTo(dir, rule.To)
+		} else {
+			ruleIDs, err = p.recipients(dir)
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("rule %s: %v", rule.label(i), err)
+		}
+
+		for _, id := range ruleIDs {
+			if _, ok := messages[id]; !ok {
+				ids = append(ids, id)
 			}
+			messages[id] = appendUnique(messages[id], ruleMessage...)
 		}
+	}
 
-		// send file notification
-		for _, value := range trimElement(p.Config.File) {
-			if err := client.Attachment(To, messenger.FileAttachment, value, messenger.ResponseType); err != nil {
-				log.Println("error to send the file:", err)
-				continue
+	return ids, messages, nil
+}
+
+// defaultMessage returns the templates sent to recipients without a rule message.
+func (p Plugin) defaultMessage() ([]string, error) {
+	if values := p.templateValues(); len(values) > 0 {
+		return p.loadTemplates(values)
+	}
+
+	return p.Message(), nil
+}
+
+// resolveTo turns the to setting into IDs, expanding names and groups
+// of the recipients file. Duplicate IDs are notified once.
+func (p Plugin) resolveTo(dir *Directory, to []string) ([]int64, error) {
+	if err := p.checkTo(to, dir); err != nil {
+		return nil, err
+	}
+
+	to, err := dir.Expand(to)
+	if err != nil {
+		return nil, err
+	}
+
+	return uniqueIDs(parseTo(to, p.Commit.Email, p.Config.MatchEmail)), nil
+}
+
+// uniqueIDs drops the repeated IDs, keeping the first position.
+func uniqueIDs(ids []int64) []int64 {
+	seen := make(map[int64]bool, len(ids))
+	out := ids[:0]
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		out = append(out, id)
+	}
+
+	return out
+}
+
+func appendUnique(list []string, values ...string) []string {
+	for _, value := range values {
+		found := false
+		for _, item := range list {
+			if item == value {
+				found = true
+				break
 			}
 		}
+		if !found {
+			list = append(list, value)
+		}
 	}
 
-	return nil
+	return list
+}
+
+// notify sends every configured message to a single recipient.
+func (p Plugin) notify(client sender, user int64, message []string, extras []part, report *sendReport) {
+	parts := append(p.parts(user, message, report), extras...)
+
+	// quick replies only show on the latest message of the conversation.
+	if replies := p.quickReplies(); len(replies) > 0 && len(parts) > 0 {
+		last := parts[len(parts)-1]
+		m := *last.message
+		m.QuickReplies = replies
+		parts[len(parts)-1].message = &m
+	}
+
+	if p.Config.Typing && len(parts) > 0 {
+		p.action(client, user, TypingOn)
+		defer p.action(client, user, TypingOff)
+	}
+
+	for i, part := range parts {
+		if i > 0 && p.Config.PartDelay > 0 {
+			time.Sleep(p.Config.PartDelay)
+		}
+		p.deliver(client, user, part, report)
+	}
+}
+
+// action shows a sender action, failures only log as the notification
+// itself may still get through.
+func (p Plugin) action(client sender, user int64, action string) {
+	req := senderAction(user, action)
+	req.PersonaID = p.Config.PersonaID
+
+	if err := client.Dispatch(req); err != nil {
+		log.Println("error to send the "+action+" action:", err)
+	}
+}
+
+// parts renders the text messages of a notification, templates failing
+// to render are recorded as failures of the recipient.
+func (p Plugin) parts(user int64, message []string, report *sendReport) []part {
+	var parts []part
+
+	// send text notification
+	for _, value := range trimElement(message) {
+		text, err := template.RenderTrim(value, p)
+		if err != nil {
+			log.Println("error to parse the template:", err)
+			report.fail(user, "template", err)
+			continue
+		}
+
+		kind, m := p.textNotification(text)
+		if kind != "text" {
+			parts = append(parts, part{kind: kind, message: m})
+			continue
+		}
+
+		for _, chunk := range p.texts(text) {
+			parts = append(parts, part{kind: kind, message: textMessage(chunk)})
+		}
+	}
+
+	return parts
+}
+
+// extras returns the parts every recipient gets after the text messages:
+// the carousel and the attachments.
+func (p Plugin) extras() ([]part, error) {
+	var parts []part
+
+	carousel, err := p.carousel()
+	if err != nil {
+		return nil, err
+	}
+	if len(carousel) > 0 {
+		parts = append(parts, part{kind: "carousel", message: genericMessage(carousel)})
+	}
+
+	attachments, err := p.attachments()
+	if err != nil {
+		return nil, err
+	}
+
+	return append(parts, attachments...), nil
+}
+
+// deliver sends a single message and records the result.
+func (p Plugin) deliver(client sender, user int64, part part, report *sendReport) {
+	req := p.request(user, part.message)
+	req.File = part.file
+
+	err := client.Dispatch(req)
+	if err != nil && part.reused != nil {
+		// the cached id expired or belongs to another page
+		log.Println("error to send the cached "+part.kind+", sending it again:", err)
+		part.reused.evict()
+
+		req = p.request(user, part.reused.original.message)
+		req.File = part.reused.original.file
+		err = client.Dispatch(req)
+	}
+
+	if err != nil {
+		log.Println("error to send the "+part.kind+":", err)
+		report.fail(user, part.kind, err)
+		return
+	}
+
+	report.success(user)
 }
 
 // Message is plugin default message.
@@ -56,7 +56,7 @@ func TestErrorTemplate(t *testing.T) {
 	}
 
 	err := plugin.Exec()
-	assert.NoError(t, err)
+	assert.EqualError(t, err, "can't read template xxxxx/xxxxx: open xxxxx/xxxxx: no such file or directory")
 }
 
 func TestSendMessage(t *testing.T) {
@@ -181,3 +181,50 @@ func TestDefaultRouter(t *testing.T) {
 	w = performRequest(router, "GET", "/metrics")
 	assert.Equal(t, 200, w.Code)
 }
+
+func TestParallelSend(t *testing.T) {
+	plugin := Plugin{
+		Config: Config{
+			To:          []string{"1", "2", "3", "4", "5", "6"},
+			Message:     []string{"first", "second"},
+			Image:       []string{"https://example.com/1.png"},
+			File:        []string{"https://example.com/1.pdf"},
+			Parallelism: 3,
+		},
+	}
+	client := &fakeSender{}
+
+	assert.NoError(t, plugin.send(client, nil))
+	assert.Len(t, client.sent, 6)
+	for id, sent := range client.sent {
+		assert.Equal(t, []string{
+			"first",
+			"second",
+			"image:https://example.com/1.png",
+			"file:https://example.com/1.pdf",
+		}, sent, "recipient %d", id)
+	}
+}
+
+func TestTypingIndicator(t *testing.T) {
+	plugin := Plugin{
+		Config: Config{
+			Message: []string{"first", "second"},
+			Typing:  true,
+		},
+	}
+	client := &recordSender{}
+
+	plugin.notify(client, 1, plugin.Config.Message, nil, &sendReport{})
+	assert.Len(t, client.requests, 4)
+	assert.Equal(t, senderAction(1, TypingOn), client.requests[0])
+	assert.Nil(t, client.requests[0].Message)
+	assert.Equal(t, "first", client.requests[1].Message.Text)
+	assert.Equal(t, "second", client.requests[2].Message.Text)
+	assert.Equal(t, senderAction(1, TypingOff), client.requests[3])
+
+	// no typing indicator without messages
+	client = &recordSender{}
+	plugin.notify(client, 1, nil, nil, &sendReport{})
+	assert.Empty(t, client.requests)
+}
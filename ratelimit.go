@@ -0,0 +1,57 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// rateLimitSender spaces out requests shared by all workers so the
+// whole run stays under a requests per second ceiling.
+type rateLimitSender struct {
+	sync.Mutex
+	sender   sender
+	interval time.Duration
+	next     time.Time
+	sleep    func(time.Duration)
+}
+
+func newRateLimitSender(s sender, rps float64) *rateLimitSender {
+	return &rateLimitSender{
+		sender:   s,
+		interval: time.Duration(float64(time.Second) / rps),
+		sleep:    time.Sleep,
+	}
+}
+
+// Dispatch sends the request once a slot is free.
+func (r *rateLimitSender) Dispatch(req *sendRequest) error {
+	r.wait()
+	return r.sender.Dispatch(req)
+}
+
+// Upload uploads the attachment with the wrapped sender once a slot is free.
+func (r *rateLimitSender) Upload(dataType string, url, file string) (string, error) {
+	up, ok := r.sender.(uploader)
+	if !ok {
+		return "", errNoUpload
+	}
+
+	r.wait()
+	return up.Upload(dataType, url, file)
+}
+
+// wait reserves the next free slot and sleeps until it starts.
+func (r *rateLimitSender) wait() {
+	r.Lock()
+	now := time.Now()
+	if r.next.Before(now) {
+		r.next = now
+	}
+	delay := r.next.Sub(now)
+	r.next = r.next.Add(r.interval)
+	r.Unlock()
+
+	if delay > 0 {
+		r.sleep(delay)
+	}
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimitSender(t *testing.T) {
+	var delays []time.Duration
+	client := newRateLimitSender(&fakeSender{}, 10)
+	client.sleep = func(d time.Duration) { delays = append(delays, d) }
+
+	for i := 0; i < 4; i++ {
+		assert.NoError(t, client.Dispatch(Plugin{}.request(1, textMessage("hi"))))
+	}
+
+	// the first request goes out right away, the next ones are spaced 100ms apart.
+	assert.Len(t, delays, 3)
+	for i, d := range delays {
+		assert.InDelta(t, float64(time.Duration(i+1)*100*time.Millisecond), float64(d), float64(20*time.Millisecond))
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Person is a named recipient of the recipients file.
+type Person struct {
+	Name   string   `yaml:"name"`
+	ID     int64    `yaml:"id"`
+	Emails []string `yaml:"emails"`
+	Groups []string `yaml:"groups"`
+}
+
+// Directory maps names and groups to page-scoped IDs.
+type Directory struct {
+	People []Person `yaml:"people"`
+}
+
+// directory loads the recipients file, which may be yaml or json.
+// It returns nil when no file is configured.
+func (p Plugin) directory() (*Directory, error) {
+	if p.Config.RecipientsFile == "" {
+		return nil, nil
+	}
+
+	name := p.Config.RecipientsFile
+	if !filepath.IsAbs(name) {
+		name = filepath.Join(p.workspace(), name)
+	}
+
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := &Directory{}
+	if err := yaml.UnmarshalStrict(data, dir); err != nil {
+		return nil, fmt.Errorf("can't parse recipients file %s: %v", p.Config.RecipientsFile, err)
+	}
+
+	for _, person := range dir.People {
+		if person.Name == "" || person.ID == 0 {
+			return nil, fmt.Errorf("recipients file %s: every person needs a name and an id", p.Config.RecipientsFile)
+		}
+	}
+
+	return dir, nil
+}
+
+// Expand replaces names and @group references in to with the IDs they
+// stand for, so the result can be handed to parseTo. A :email suffix is
+// kept on the expanded IDs.
+func (d *Directory) Expand(to []string) ([]string, error) {
+	if d == nil {
+		return to, nil
+	}
+
+	var out []string
+	var unknown []string
+	seen := make(map[string]bool)
+	add := func(value string) {
+		if !seen[value] {
+			seen[value] = true
+			out = append(out, value)
+		}
+	}
+
+	for _, value := range trimElement(to) {
+		fields := strings.SplitN(value, ":", 2)
+		key := strings.TrimSpace(fields[0])
+		if _, err := strconv.ParseInt(key, 10, 64); err == nil {
+			add(value)
+			continue
+		}
+
+		// keep the :email condition of name:email entries
+		suffix := ""
+		if len(fields) == 2 {
+			suffix = ":" + strings.TrimSpace(fields[1])
+		}
+
+		people := d.lookup(key)
+		if len(people) == 0 {
+			unknown = append(unknown, key)
+			continue
+		}
+		for _, person := range people {
+			add(strconv.FormatInt(person.ID, 10) + suffix)
+		}
+	}
+
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("unknown recipients: %s", strings.Join(unknown, ", "))
+	}
+
+	return out, nil
+}
+
+// lookup finds a person by name or the members of an @group.
+func (d *Directory) lookup(name string) []Person {
+	var people []Person
+
+	group := strings.HasPrefix(name, "@")
+	name = strings.TrimPrefix(name, "@")
+	for _, person := range d.People {
+		if !group && strings.EqualFold(person.Name, name) {
+			return []Person{person}
+		}
+		if group && containsFold(person.Groups, name) {
+			people = append(people, person)
+		}
+	}
+
+	return people
+}
+
+func containsFold(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDirectoryExpand(t *testing.T) {
+	dir := &Directory{
+		People: []Person{
+			{Name: "alice", ID: 1, Groups: []string{"oncall", "backend"}},
+			{Name: "bob", ID: 2, Groups: []string{"oncall"}},
+			{Name: "carol", ID: 3},
+		},
+	}
+
+	to, err := dir.Expand([]string{"@oncall", "Carol", "bob", "4:4@gmail.com", "5"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1", "2", "3", "4:4@gmail.com", "5"}, to)
+
+	// the email condition of name:email entries is kept
+	to, err = dir.Expand([]string{"alice:alice@example.com", "@oncall:oncall@example.com"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1:alice@example.com", "1:oncall@example.com", "2:oncall@example.com"}, to)
+
+	_, err = dir.Expand([]string{"alice", "dave", "@frontend"})
+	assert.EqualError(t, err, "unknown recipients: dave, @frontend")
+
+	// without a recipients file the to setting is kept as is
+	dir = nil
+	to, err = dir.Expand([]string{"alice"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"alice"}, to)
+}
+
+func TestRecipientsFile(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "drone-facebook")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tmp)
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(tmp, "recipients.json"), []byte(`{
+  "people": [
+    {"name": "alice", "id": 1, "emails": ["alice@example.com"], "groups": ["oncall"]},
+    {"name": "bob", "id": 2, "groups": ["oncall"]}
+  ]
+}`), 0644))
+
+	plugin := Plugin{
+		Config: Config{
+			Workspace:      tmp,
+			RecipientsFile: "recipients.json",
+			To:             []string{"@oncall", "alice", "3"},
+		},
+	}
+
+	ids, _, err := plugin.plan()
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1, 2, 3}, ids)
+
+	// alice only gets the message of her own commits with match_email
+	plugin.Config.To = []string{"alice:alice@example.com", "bob"}
+	plugin.Config.MatchEmail = true
+	plugin.Commit.Email = "bob@example.com"
+	ids, _, err = plugin.plan()
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{2}, ids)
+
+	plugin.Commit.Email = "alice@example.com"
+	ids, _, err = plugin.plan()
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1}, ids)
+
+	plugin.Config.To = []string{"mallory"}
+	_, _, err = plugin.plan()
+	assert.EqualError(t, err, "unknown recipients: mallory")
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Failure policies for Exec.
+const (
+	// FailOnAny fails the step when any message can't be delivered.
+	FailOnAny = "any"
+	// FailOnAll fails the step only when no recipient got any message.
+	FailOnAll = "all"
+	// FailNever only logs delivery errors.
+	FailNever = "never"
+)
+
+// sender delivers Send API requests.
+type sender interface {
+	Dispatch(req *sendRequest) error
+}
+
+// SendError is a failed delivery to a single recipient.
+type SendError struct {
+	ID   int64
+	Kind string
+	Err  error
+}
+
+func (e SendError) Error() string {
+	return fmt.Sprintf("recipient %d (%s): %v", e.ID, e.Kind, e.Err)
+}
+
+// MultiError collects every failed delivery of a run.
+type MultiError struct {
+	Errors []SendError
+}
+
+func (e MultiError) Error() string {
+	lines := make([]string, 0, len(e.Errors)+1)
+	lines = append(lines, fmt.Sprintf("failed to send %d message(s):", len(e.Errors)))
+	for _, err := range e.Errors {
+		lines = append(lines, "  - "+err.Error())
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// sendReport tracks delivery results per recipient.
+type sendReport struct {
+	sync.Mutex
+	reached map[int64]bool
+	errors  []SendError
+}
+
+func (r *sendReport) success(id int64) {
+	r.Lock()
+	defer r.Unlock()
+	if r.reached == nil {
+		r.reached = make(map[int64]bool)
+	}
+	r.reached[id] = true
+}
+
+func (r *sendReport) fail(id int64, kind string, err error) {
+	r.Lock()
+	defer r.Unlock()
+	r.errors = append(r.errors, SendError{ID: id, Kind: kind, Err: err})
+}
+
+func checkPolicy(policy string) error {
+	switch policy {
+	case "", FailOnAny, FailOnAll, FailNever:
+		return nil
+	}
+
+	return fmt.Errorf("unknown failure policy: %s", policy)
+}
+
+// check applies the failure policy to the collected results.
+func (r *sendReport) check(policy string) error {
+	if len(r.errors) == 0 {
+		return nil
+	}
+
+	switch policy {
+	case FailNever:
+		return nil
+	case FailOnAll:
+		if len(r.reached) > 0 {
+			return nil
+		}
+	}
+
+	return MultiError{Errors: r.errors}
+}
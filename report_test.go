@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeSender struct {
+	sync.Mutex
+	fail map[int64]bool
+	sent map[int64][]string
+}
+
+func (f *fakeSender) Dispatch(req *sendRequest) error {
+	f.Lock()
+	defer f.Unlock()
+	id := req.Recipient.ID
+	if f.fail[id] {
+		return errors.New("Facebook error : invalid user")
+	}
+	if f.sent == nil {
+		f.sent = make(map[int64][]string)
+	}
+	f.sent[id] = append(f.sent[id], describe(req.Message))
+	return nil
+}
+
+// describe summarises a message for assertions.
+func describe(m *messageData) string {
+	switch {
+	case m == nil:
+		return ""
+	case m.Attachment == nil:
+		return m.Text
+	case m.Attachment.Type == "template":
+		return "template:" + m.Attachment.Payload.TemplateType
+	}
+
+	return m.Attachment.Type + ":" + m.Attachment.Payload.URL
+}
+
+func TestFailurePolicy(t *testing.T) {
+	plugin := Plugin{
+		Config: Config{
+			To:      []string{"1", "2"},
+			Message: []string{"hello"},
+			Image:   []string{"https://example.com/1.png"},
+		},
+	}
+	client := &fakeSender{fail: map[int64]bool{2: true}}
+
+	err := plugin.send(client, nil)
+	assert.Error(t, err)
+	assert.Equal(t, "failed to send 2 message(s):\n"+
+		"  - recipient 2 (text): Facebook error : invalid user\n"+
+		"  - recipient 2 (image): Facebook error : invalid user", err.Error())
+
+	plugin.Config.FailurePolicy = FailOnAll
+	assert.NoError(t, plugin.send(client, nil))
+
+	client.fail[1] = true
+	assert.Error(t, plugin.send(client, nil))
+
+	plugin.Config.FailurePolicy = FailNever
+	assert.NoError(t, plugin.send(client, nil))
+
+	// templates failing to render count as failures
+	plugin.Config.FailurePolicy = FailOnAny
+	plugin.Config.To = []string{"1"}
+	plugin.Config.Message = []string{"{{ build.number"}
+	client = &fakeSender{}
+	err = plugin.send(client, nil)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "recipient 1 (template)")
+	assert.Equal(t, []string{"image:https://example.com/1.png"}, client.sent[1])
+
+	plugin.Config.FailurePolicy = "sometimes"
+	assert.EqualError(t, plugin.send(client, nil), "unknown failure policy: sometimes")
+}
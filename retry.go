@@ -0,0 +1,112 @@
+package main
+
+import (
+	"log"
+	"math/rand"
+	"net"
+	"time"
+)
+
+const (
+	retryBaseDelay = 500 * time.Millisecond
+	retryMaxDelay  = 10 * time.Second
+)
+
+// transientCodes are Graph API error codes worth retrying: unknown and
+// service errors plus the application, user and page rate limits.
+var transientCodes = map[int]bool{
+	1:   true, // API unknown
+	2:   true, // API service
+	4:   true, // application request limit reached
+	17:  true, // user request limit reached
+	32:  true, // page request limit reached
+	613: true, // calls within one hour exceeded
+}
+
+func init() {
+	rand.Seed(time.Now().UnixNano())
+}
+
+// isTransient reports whether a failed send might succeed when retried.
+// Invalid recipients, expired tokens and messages outside the 24 hours
+// window are permanent and returned right away.
+func isTransient(err error) bool {
+	switch e := err.(type) {
+	case *GraphError:
+		return transientCodes[e.Code] || e.StatusCode >= 500
+	case net.Error:
+		return true
+	}
+
+	return false
+}
+
+// retrySender retries transient errors with exponential backoff and full jitter.
+type retrySender struct {
+	sender      sender
+	maxAttempts int
+	maxElapsed  time.Duration
+	sleep       func(time.Duration)
+}
+
+func newRetrySender(s sender, maxAttempts int, maxElapsed time.Duration) *retrySender {
+	return &retrySender{
+		sender:      s,
+		maxAttempts: maxAttempts,
+		maxElapsed:  maxElapsed,
+		sleep:       time.Sleep,
+	}
+}
+
+// Dispatch sends the request, retrying transient errors.
+func (r *retrySender) Dispatch(req *sendRequest) error {
+	return r.do(func() error {
+		return r.sender.Dispatch(req)
+	})
+}
+
+// Upload uploads the attachment with the wrapped sender, retrying
+// transient errors.
+func (r *retrySender) Upload(dataType string, url, file string) (string, error) {
+	up, ok := r.sender.(uploader)
+	if !ok {
+		return "", errNoUpload
+	}
+
+	var id string
+	err := r.do(func() (err error) {
+		id, err = up.Upload(dataType, url, file)
+		return err
+	})
+
+	return id, err
+}
+
+func (r *retrySender) do(fn func() error) error {
+	start := time.Now()
+
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || !isTransient(err) || attempt >= r.maxAttempts {
+			return err
+		}
+
+		delay := backoff(attempt)
+		if r.maxElapsed > 0 && time.Since(start)+delay > r.maxElapsed {
+			return err
+		}
+
+		log.Printf("retrying in %v (attempt %d/%d): %v\n", delay, attempt+1, r.maxAttempts, err)
+		r.sleep(delay)
+	}
+}
+
+// backoff returns a random delay up to base*2^(attempt-1), capped at retryMaxDelay.
+func backoff(attempt int) time.Duration {
+	max := retryBaseDelay << uint(attempt-1)
+	if max <= 0 || max > retryMaxDelay {
+		max = retryMaxDelay
+	}
+
+	return time.Duration(rand.Int63n(int64(max)) + 1)
+}
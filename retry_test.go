@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/paked/messenger"
+	"github.com/stretchr/testify/assert"
+)
+
+type flakySender struct {
+	errs  []error
+	calls int
+}
+
+func (f *flakySender) Dispatch(req *sendRequest) error {
+	f.calls++
+	if len(f.errs) == 0 {
+		return nil
+	}
+	err := f.errs[0]
+	f.errs = f.errs[1:]
+	return err
+}
+
+func (f *flakySender) Upload(dataType string, url, file string) (string, error) {
+	if err := f.Dispatch(nil); err != nil {
+		return "", err
+	}
+	return "id", nil
+}
+
+func TestIsTransient(t *testing.T) {
+	assert.True(t, isTransient(&GraphError{Code: 613}))
+	assert.True(t, isTransient(&GraphError{Code: 4}))
+	assert.True(t, isTransient(&GraphError{Code: 100, StatusCode: 503}))
+	assert.False(t, isTransient(&GraphError{Code: 100, Subcode: 2018001, StatusCode: 400}))
+	assert.False(t, isTransient(&GraphError{Code: 190, StatusCode: 400}))
+	assert.False(t, isTransient(&GraphError{Code: 10, Subcode: 2018278, StatusCode: 400}))
+	assert.False(t, isTransient(errors.New("boom")))
+}
+
+func TestRetrySender(t *testing.T) {
+	var delays []time.Duration
+	flaky := &flakySender{errs: []error{&GraphError{Code: 2}, &GraphError{Code: 17}}}
+	client := newRetrySender(flaky, 3, 0)
+	client.sleep = func(d time.Duration) { delays = append(delays, d) }
+
+	assert.NoError(t, client.Dispatch(Plugin{}.request(1, textMessage("hi"))))
+	assert.Equal(t, 3, flaky.calls)
+	assert.Len(t, delays, 2)
+
+	// give up after max attempts
+	flaky = &flakySender{errs: []error{&GraphError{Code: 2}, &GraphError{Code: 2}, &GraphError{Code: 2}}}
+	client.sender = flaky
+	assert.Error(t, client.Dispatch(Plugin{}.request(1, textMessage("hi"))))
+	assert.Equal(t, 3, flaky.calls)
+
+	// permanent errors are not retried
+	flaky = &flakySender{errs: []error{&GraphError{Code: 190}}}
+	client.sender = flaky
+	assert.Error(t, client.Dispatch(Plugin{}.request(1, attachmentMessage(messenger.ImageAttachment, "https://example.com/1.png"))))
+	assert.Equal(t, 1, flaky.calls)
+
+	// uploads are retried too
+	flaky = &flakySender{errs: []error{&GraphError{Code: 613}}}
+	client.sender = flaky
+	id, err := client.Upload("image", "https://example.com/1.png", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "id", id)
+	assert.Equal(t, 2, flaky.calls)
+
+	client.sender = &fakeSender{}
+	_, err = client.Upload("image", "https://example.com/1.png", "")
+	assert.Equal(t, errNoUpload, err)
+}
+
+func TestBackoff(t *testing.T) {
+	for attempt := 1; attempt < 100; attempt++ {
+		d := backoff(attempt)
+		assert.True(t, d > 0 && d <= retryMaxDelay, fmt.Sprintf("attempt %d: %v", attempt, d))
+	}
+}
+
+func TestGraphClientError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "access_token=token", r.URL.RawQuery)
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error":{"message":"No matching user found","type":"OAuthException","code":100,"error_subcode":2018001,"fbtrace_id":"abc"}}`)
+	}))
+	defer ts.Close()
+
+	client := newGraphClient("token")
+	client.url = ts.URL
+
+	err := client.Dispatch(Plugin{}.request(1, textMessage("hi")))
+	assert.Equal(t, &GraphError{
+		StatusCode: http.StatusBadRequest,
+		Message:    "No matching user found",
+		Type:       "OAuthException",
+		Code:       100,
+		Subcode:    2018001,
+		FBTraceID:  "abc",
+	}, err)
+	assert.EqualError(t, err, "Facebook error : No matching user found (code 100, subcode 2018001)")
+}
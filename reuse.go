@@ -0,0 +1,235 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// attachmentUploadURL is the Attachment Upload API endpoint.
+const attachmentUploadURL = graphURL + "/me/message_attachments"
+
+// errNoUpload is returned by sender wrappers around a sender that can't
+// upload attachments.
+var errNoUpload = errors.New("the sender can't upload attachments")
+
+// fetchClient downloads attachment URLs to hash their content.
+var fetchClient = &http.Client{
+	Timeout: time.Minute,
+}
+
+// uploader uploads attachments once and returns an ID reusable for
+// every recipient.
+type uploader interface {
+	Upload(dataType string, url, file string) (string, error)
+}
+
+// uploadRequest is an Attachment Upload API request body.
+type uploadRequest struct {
+	Message *messageData `json:"message"`
+}
+
+// Upload saves the attachment at Facebook, from the URL or the local file.
+func (g *graphClient) Upload(dataType string, url, file string) (string, error) {
+	m := &uploadRequest{
+		Message: &messageData{
+			Attachment: &attachmentData{
+				Type: dataType,
+				Payload: payloadData{
+					URL:        url,
+					IsReusable: true,
+				},
+			},
+		},
+	}
+
+	var result struct {
+		AttachmentID string `json:"attachment_id"`
+	}
+
+	if file != "" {
+		body, contentType, err := multipartBody(m, file)
+		if err != nil {
+			return "", err
+		}
+		if err := g.do("POST", g.uploadURL, body, contentType, &result); err != nil {
+			return "", err
+		}
+	} else if err := g.post(g.uploadURL, m, &result); err != nil {
+		return "", err
+	}
+
+	if result.AttachmentID == "" {
+		return "", fmt.Errorf("missing attachment id in the upload response")
+	}
+
+	return result.AttachmentID, nil
+}
+
+// attachmentCache maps attachment content hashes to reusable attachment
+// IDs, keyed by page as the IDs only work for the page that uploaded them.
+type attachmentCache struct {
+	sync.Mutex
+	path string
+	IDs  map[string]string `json:"ids"`
+}
+
+// reusedAttachment is the cache entry a part sends by ID, with the part
+// to send instead when Facebook rejects the ID.
+type reusedAttachment struct {
+	cache    *attachmentCache
+	key      string
+	original part
+}
+
+func loadAttachmentCache(path string) (*attachmentCache, error) {
+	c := &attachmentCache{path: path, IDs: make(map[string]string)}
+	if path == "" {
+		return c, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("can't parse attachment cache %s: %v", path, err)
+	}
+	if c.IDs == nil {
+		c.IDs = make(map[string]string)
+	}
+
+	return c, nil
+}
+
+// evict drops the entry so the next run uploads the attachment again.
+func (r *reusedAttachment) evict() {
+	r.cache.Lock()
+	defer r.cache.Unlock()
+
+	if _, ok := r.cache.IDs[r.key]; !ok {
+		return
+	}
+	delete(r.cache.IDs, r.key)
+
+	if err := r.cache.save(); err != nil {
+		log.Println("error to save the attachment cache:", err)
+	}
+}
+
+func (c *attachmentCache) save() error {
+	if c.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(c.path, data, 0644)
+}
+
+// reuseAttachments uploads every attachment once and turns the parts into
+// messages sending the attachment by ID. Parts that can't be uploaded are
+// kept as they are, parts whose ID is rejected are sent as they were.
+func (p Plugin) reuseAttachments(up uploader, parts []part) ([]part, error) {
+	path := p.Config.AttachmentCache
+	if path != "" && !filepath.IsAbs(path) {
+		path = filepath.Join(p.workspace(), path)
+	}
+
+	cache, err := loadAttachmentCache(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// the token stands for the page, hashed to keep it out of the file
+	sum := sha256.Sum256([]byte(p.Config.PageToken))
+	page := hex.EncodeToString(sum[:8])
+
+	out := make([]part, 0, len(parts))
+	for _, part := range parts {
+		a := part.message.Attachment
+		if a == nil || a.Type == "template" {
+			out = append(out, part)
+			continue
+		}
+
+		url := a.Payload.URL
+		hash, err := contentHash(url, part.file)
+		if err != nil {
+			log.Println("error to read the "+part.kind+":", err)
+			out = append(out, part)
+			continue
+		}
+
+		key := page + ":" + a.Type + ":" + hash
+		id, ok := cache.IDs[key]
+		if !ok {
+			if id, err = up.Upload(a.Type, url, part.file); err != nil {
+				log.Println("error to upload the "+part.kind+":", err)
+				out = append(out, part)
+				continue
+			}
+			cache.IDs[key] = id
+		}
+
+		original := part
+		part.message = reusedMessage(a.Type, id)
+		part.file = ""
+		part.reused = &reusedAttachment{cache: cache, key: key, original: original}
+		out = append(out, part)
+	}
+
+	if err := cache.save(); err != nil {
+		log.Println("error to save the attachment cache:", err)
+	}
+
+	return out, nil
+}
+
+// contentHash is the sha256 of the local file or of the content at url.
+func contentHash(url, file string) (string, error) {
+	var r io.Reader
+
+	if file != "" {
+		f, err := os.Open(file)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		r = f
+	} else {
+		resp, err := fetchClient.Get(url)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("fetch %s: %s", url, resp.Status)
+		}
+		r = io.LimitReader(resp.Body, maxAttachmentSize+1)
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
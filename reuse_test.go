@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paked/messenger"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeUploader struct {
+	uploads []string
+	err     error
+}
+
+func (f *fakeUploader) Upload(dataType string, url, file string) (string, error) {
+	if f.err != nil {
+		return "", f.err
+	}
+	f.uploads = append(f.uploads, dataType+":"+url+file)
+	return "id-" + filepath.Base(url+file), nil
+}
+
+func TestReuseAttachments(t *testing.T) {
+	dir, err := ioutil.TempDir("", "drone-facebook")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	name := filepath.Join(dir, "coverage.png")
+	assert.NoError(t, ioutil.WriteFile(name, pngData, 0644))
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(pngData)
+	}))
+	defer ts.Close()
+
+	plugin := Plugin{
+		Config: Config{
+			Workspace:       dir,
+			AttachmentCache: "cache.json",
+		},
+	}
+
+	parts := []part{
+		{kind: "text", message: textMessage("hi")},
+		{kind: "image", message: attachmentMessage(messenger.ImageAttachment, ts.URL+"/1.png")},
+		{kind: "image", message: uploadMessage(messenger.ImageAttachment), file: name},
+	}
+
+	up := &fakeUploader{}
+	out, err := plugin.reuseAttachments(up, parts)
+	assert.NoError(t, err)
+	assert.Equal(t, parts[0], out[0])
+	assert.Equal(t, reusedMessage("image", "id-1.png"), out[1].message)
+	// same content, uploaded once
+	assert.Equal(t, reusedMessage("image", "id-1.png"), out[2].message)
+	assert.Empty(t, out[2].file)
+	assert.Len(t, up.uploads, 1)
+
+	// later runs read the ids from the cache
+	up = &fakeUploader{err: errors.New("boom")}
+	out, err = plugin.reuseAttachments(up, parts)
+	assert.NoError(t, err)
+	assert.Equal(t, reusedMessage("image", "id-1.png"), out[2].message)
+	assert.Empty(t, up.uploads)
+
+	// ids of another page aren't reused
+	plugin.Config.PageToken = "other"
+	other := &fakeUploader{}
+	out, err = plugin.reuseAttachments(other, parts)
+	assert.NoError(t, err)
+	assert.Len(t, other.uploads, 1)
+
+	// rejected ids are dropped and the original part is sent instead
+	flaky := &flakySender{errs: []error{&GraphError{Code: 100, StatusCode: 400}}}
+	report := &sendReport{}
+	plugin.deliver(flaky, 1, out[2], report)
+	assert.Equal(t, 2, flaky.calls)
+	assert.NoError(t, report.check(FailOnAny))
+
+	cache, err := loadAttachmentCache(filepath.Join(dir, "cache.json"))
+	assert.NoError(t, err)
+	assert.NotContains(t, cache.IDs, out[2].reused.key)
+	assert.Len(t, cache.IDs, 1)
+
+	// failed uploads keep the original part
+	plugin.Config.AttachmentCache = ""
+	out, err = plugin.reuseAttachments(up, parts)
+	assert.NoError(t, err)
+	assert.Equal(t, parts, out)
+}
+
+func TestGraphClientUploadReusable(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, map[string]interface{}{
+			"message": map[string]interface{}{
+				"attachment": map[string]interface{}{
+					"type": "image",
+					"payload": map[string]interface{}{
+						"url":         "https://example.com/1.png",
+						"is_reusable": true,
+					},
+				},
+			},
+		}, body)
+
+		w.Write([]byte(`{"attachment_id":"1857777774821032"}`))
+	}))
+	defer ts.Close()
+
+	client := newGraphClient("token")
+	client.uploadURL = ts.URL
+
+	id, err := client.Upload("image", "https://example.com/1.png", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "1857777774821032", id)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/paked/messenger"
+)
+
+type (
+	// Command is a chat command of the webhook server.
+	Command struct {
+		Name string
+		// Args describes the arguments in the usage, like "owner/repo [branch]".
+		Args    string
+		Help    string
+		MinArgs int
+		MaxArgs int
+		// Authorize restricts the command, every user may run it when nil.
+		Authorize func(user int64) bool
+		Run       func(ctx *Context) string
+	}
+
+	// Context is a command invocation.
+	Context struct {
+		User    int64
+		Name    string
+		Args    []string
+		Store   *Store
+		Command *Command
+		// QuickReplies are attached to the reply.
+		QuickReplies []messenger.QuickReply
+	}
+
+	// Router dispatches chat messages to the registered commands.
+	Router struct {
+		commands map[string]*Command
+	}
+)
+
+// Usage returns the usage line of the command.
+func (c *Command) Usage() string {
+	if c.Args == "" {
+		return c.Name
+	}
+
+	return c.Name + " " + c.Args
+}
+
+// Usage is the reply to invalid arguments.
+func (ctx *Context) Usage() string {
+	return "Usage: " + ctx.Command.Usage()
+}
+
+// NewRouter returns a router with the help command.
+func NewRouter() *Router {
+	r := &Router{commands: make(map[string]*Command)}
+	r.Register(Command{
+		Name: "help",
+		Help: "list the commands",
+		Run:  r.help,
+	})
+
+	return r
+}
+
+// Register adds the command, replacing a command of the same name.
+func (r *Router) Register(cmd Command) {
+	r.commands[strings.ToLower(cmd.Name)] = &cmd
+}
+
+// Handle parses text as a command with arguments, runs it and returns
+// the reply.
+func (r *Router) Handle(ctx *Context, text string) string {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return `Send "help" to list the commands.`
+	}
+
+	cmd, ok := r.commands[strings.ToLower(fields[0])]
+	if !ok {
+		return fmt.Sprintf(`Unknown command "%s", send "help" to list the commands.`, fields[0])
+	}
+
+	if cmd.Authorize != nil && !cmd.Authorize(ctx.User) {
+		return fmt.Sprintf("You are not allowed to run %s.", cmd.Name)
+	}
+
+	ctx.Command = cmd
+	ctx.Args = fields[1:]
+	if len(ctx.Args) < cmd.MinArgs || (cmd.MaxArgs >= 0 && len(ctx.Args) > cmd.MaxArgs) {
+		return ctx.Usage()
+	}
+
+	return cmd.Run(ctx)
+}
+
+// help lists the commands the user is allowed to run.
+func (r *Router) help(ctx *Context) string {
+	names := make([]string, 0, len(r.commands))
+	for name := range r.commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	lines := []string{"Commands:"}
+	for _, name := range names {
+		cmd := r.commands[name]
+		if cmd.Authorize != nil && !cmd.Authorize(ctx.User) {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("- %s: %s", cmd.Usage(), cmd.Help))
+	}
+
+	return strings.Join(lines, "\n")
+}
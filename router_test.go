@@ -0,0 +1,49 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouter(t *testing.T) {
+	router := NewRouter()
+	router.Register(Command{
+		Name:    "echo",
+		Args:    "<text>...",
+		Help:    "repeat the text",
+		MinArgs: 1,
+		MaxArgs: -1,
+		Run: func(ctx *Context) string {
+			return ctx.Name + ": " + strings.Join(ctx.Args, " ")
+		},
+	})
+	router.Register(Command{
+		Name:      "secret",
+		Help:      "admins only",
+		Authorize: func(user int64) bool { return user == 1 },
+		Run: func(ctx *Context) string {
+			return "granted"
+		},
+	})
+
+	admin := &Context{User: 1, Name: "Bo"}
+	user := &Context{User: 2, Name: "Wu"}
+
+	assert.Equal(t, "Wu: hello world", router.Handle(user, "ECHO hello  world"))
+	assert.Equal(t, "Usage: echo <text>...", router.Handle(user, "echo"))
+	assert.Equal(t, "granted", router.Handle(admin, "secret"))
+	assert.Equal(t, "Usage: secret", router.Handle(admin, "secret now"))
+	assert.Equal(t, "You are not allowed to run secret.", router.Handle(user, "secret"))
+	assert.Equal(t, `Unknown command "hi", send "help" to list the commands.`, router.Handle(user, "hi"))
+	assert.Equal(t, `Send "help" to list the commands.`, router.Handle(user, "  "))
+
+	assert.Equal(t, "Commands:\n"+
+		"- echo <text>...: repeat the text\n"+
+		"- help: list the commands\n"+
+		"- secret: admins only", router.Handle(admin, "help"))
+	assert.Equal(t, "Commands:\n"+
+		"- echo <text>...: repeat the text\n"+
+		"- help: list the commands", router.Handle(user, "help"))
+}
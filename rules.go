@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Rule selects recipients and templates for the builds it matches.
+// Empty conditions match everything.
+type Rule struct {
+	Name     string   `yaml:"name"`
+	Branch   []string `yaml:"branch"`
+	Event    []string `yaml:"event"`
+	Status   []string `yaml:"status"`
+	DeployTo []string `yaml:"deploy_to"`
+	Tag      []string `yaml:"tag"`
+	Author   []string `yaml:"author"`
+	To       []string `yaml:"to"`
+	Message  []string `yaml:"message"`
+}
+
+// rules parses the rules setting and the rules file.
+func (p Plugin) rules() ([]Rule, error) {
+	var rules []Rule
+
+	if p.Config.Rules != "" {
+		if err := yaml.UnmarshalStrict([]byte(p.Config.Rules), &rules); err != nil {
+			return nil, fmt.Errorf("can't parse rules: %v", err)
+		}
+	}
+
+	if p.Config.RulesFile != "" {
+		name := p.Config.RulesFile
+		if !filepath.IsAbs(name) {
+			name = filepath.Join(p.workspace(), name)
+		}
+
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+
+		var fileRules []Rule
+		if err := yaml.UnmarshalStrict(data, &fileRules); err != nil {
+			return nil, fmt.Errorf("can't parse rules file %s: %v", p.Config.RulesFile, err)
+		}
+		rules = append(rules, fileRules...)
+	}
+
+	for i, rule := range rules {
+		for _, pattern := range concat(rule.Branch, rule.Tag, rule.Author) {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("rule %s: bad pattern %q", rule.label(i), pattern)
+			}
+		}
+	}
+
+	return rules, nil
+}
+
+// Match reports whether the rule applies to the build.
+func (r Rule) Match(p Plugin) bool {
+	return matchAny(r.Branch, p.Commit.Branch) &&
+		matchAny(r.Event, p.Build.Event) &&
+		matchAny(r.Status, p.Build.Status) &&
+		matchAny(r.DeployTo, p.Build.DeployTo) &&
+		matchAny(r.Tag, p.Build.Tag) &&
+		matchAny(lower(r.Author), strings.ToLower(p.Commit.Email))
+}
+
+func (r Rule) label(i int) string {
+	if r.Name != "" {
+		return r.Name
+	}
+
+	return fmt.Sprintf("#%d", i+1)
+}
+
+// matchAny reports whether value matches one of the glob patterns.
+func matchAny(patterns []string, value string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, value); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func lower(values []string) []string {
+	out := make([]string, len(values))
+	for i, value := range values {
+		out[i] = strings.ToLower(value)
+	}
+
+	return out
+}
+
+func concat(lists ...[]string) []string {
+	var out []string
+	for _, list := range lists {
+		out = append(out, list...)
+	}
+
+	return out
+}
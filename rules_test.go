@@ -0,0 +1,78 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testRules = `
+- name: releases
+  event: [tag]
+  tag: ["v*"]
+  to: ["1", "2"]
+  message: ["released {{ build.tag }}"]
+- name: broken master
+  branch: [master, "release/*"]
+  status: [failure]
+  to: ["2", "3"]
+  message: ["master is broken"]
+- name: deploys
+  event: [promote]
+  deploy_to: [production]
+  author: ["*@example.com"]
+  to: ["4"]
+`
+
+func TestRuleMatch(t *testing.T) {
+	plugin := Plugin{
+		Commit: Commit{
+			Branch: "release/1.0",
+			Email:  "Dev@Example.com",
+		},
+		Build: Build{
+			Event:    "promote",
+			Status:   "failure",
+			DeployTo: "production",
+		},
+	}
+
+	assert.True(t, Rule{}.Match(plugin))
+	assert.True(t, Rule{Branch: []string{"release/*"}, Status: []string{"failure"}}.Match(plugin))
+	assert.True(t, Rule{Author: []string{"*@example.com"}}.Match(plugin))
+	assert.False(t, Rule{Branch: []string{"master"}}.Match(plugin))
+	assert.False(t, Rule{Tag: []string{"v*"}}.Match(plugin))
+	assert.False(t, Rule{DeployTo: []string{"staging"}}.Match(plugin))
+}
+
+func TestRulesPlan(t *testing.T) {
+	plugin := Plugin{
+		Commit: Commit{
+			Branch: "master",
+		},
+		Build: Build{
+			Event:  "tag",
+			Tag:    "v1.0.0",
+			Status: "failure",
+		},
+		Config: Config{
+			To:    []string{"9"},
+			Rules: testRules,
+		},
+	}
+
+	ids, messages, err := plugin.plan()
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1, 2, 3}, ids)
+	assert.Equal(t, []string{"released {{ build.tag }}"}, messages[1])
+	assert.Equal(t, []string{"released {{ build.tag }}", "master is broken"}, messages[2])
+	assert.Equal(t, []string{"master is broken"}, messages[3])
+
+	plugin.Config.Rules = "- branch: [\"[\"]"
+	_, _, err = plugin.plan()
+	assert.Error(t, err)
+
+	plugin.Config.Rules = "- unknown: true"
+	_, _, err = plugin.plan()
+	assert.Error(t, err)
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// maxTextLength is the Send API limit of a text message, in characters.
+const maxTextLength = 2000
+
+// long message modes
+const (
+	LongMessageSplit    = "split"
+	LongMessageTruncate = "truncate"
+)
+
+func checkLongMessage(mode string) error {
+	switch mode {
+	case "", LongMessageSplit, LongMessageTruncate:
+		return nil
+	}
+
+	return fmt.Errorf("unknown long message mode: %s", mode)
+}
+
+// texts fits a rendered message into the text limit, either splitting it
+// into several messages or truncating it.
+func (p Plugin) texts(text string) []string {
+	if p.Config.LongMessage == LongMessageTruncate {
+		return []string{truncate(text, maxTextLength)}
+	}
+
+	chunks := splitText(text, maxTextLength)
+	if !p.Config.NumberParts || len(chunks) < 2 {
+		return chunks
+	}
+
+	// make room for the numbering, which may itself add a part.
+	for {
+		suffix := len(fmt.Sprintf(" (%d/%d)", len(chunks), len(chunks)))
+		split := splitText(text, maxTextLength-suffix)
+		done := len(split) == len(chunks)
+		chunks = split
+		if done {
+			break
+		}
+	}
+
+	for i := range chunks {
+		chunks[i] += fmt.Sprintf(" (%d/%d)", i+1, len(chunks))
+	}
+
+	return chunks
+}
+
+// splitText breaks text into chunks of at most max characters, preferring
+// paragraph, then line, then word boundaries.
+func splitText(text string, max int) []string {
+	var chunks []string
+
+	runes := []rune(strings.TrimSpace(text))
+	for len(runes) > max {
+		cut := breakPoint(runes, max)
+		if chunk := strings.TrimRightFunc(string(runes[:cut]), unicode.IsSpace); chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+		runes = []rune(strings.TrimLeftFunc(string(runes[cut:]), unicode.IsSpace))
+	}
+
+	if len(runes) > 0 {
+		chunks = append(chunks, string(runes))
+	}
+
+	return chunks
+}
+
+// breakPoint returns where to cut runes so the first chunk holds at most
+// max characters. Paragraph and line breaks are only used past half of
+// the chunk so a short first line doesn't leave a tiny message.
+func breakPoint(runes []rune, max int) int {
+	var line, word int
+
+	for i := max; i > 0; i-- {
+		switch {
+		case runes[i] == '\n' && runes[i-1] == '\n':
+			if i-1 > max/2 {
+				return i - 1
+			}
+		case runes[i] == '\n':
+			if line == 0 && i > max/2 {
+				line = i
+			}
+		case unicode.IsSpace(runes[i]):
+			if word == 0 {
+				word = i
+			}
+		}
+	}
+
+	switch {
+	case line > 0:
+		return line
+	case word > 0:
+		return word
+	}
+
+	return max
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitText(t *testing.T) {
+	assert.Equal(t, []string{"hello"}, splitText(" hello \n", 10))
+	assert.Empty(t, splitText("  ", 10))
+
+	// paragraphs first
+	assert.Equal(t, []string{"aaaa bbbb\ncc", "dddd"}, splitText("aaaa bbbb\ncc\n\ndddd", 14))
+	// then lines
+	assert.Equal(t, []string{"aaaa bbbb", "cccc dddd"}, splitText("aaaa bbbb\ncccc dddd", 14))
+	// then words
+	assert.Equal(t, []string{"aaaa bbbb", "cccc"}, splitText("aaaa bbbb cccc", 10))
+	// no boundary at all
+	assert.Equal(t, []string{"aaaa", "aaaa", "aa"}, splitText("aaaaaaaaaa", 4))
+
+	// characters, not bytes
+	text := strings.Repeat("建置失敗 ", 1000)
+	for _, chunk := range splitText(text, maxTextLength) {
+		assert.True(t, utf8.ValidString(chunk))
+		assert.True(t, utf8.RuneCountInString(chunk) <= maxTextLength)
+	}
+}
+
+func TestTexts(t *testing.T) {
+	text := strings.Repeat("word ", 1000)
+
+	chunks := Plugin{}.texts(text)
+	assert.Len(t, chunks, 3)
+	assert.Equal(t, strings.TrimSpace(text), strings.Join(chunks, " "))
+
+	chunks = Plugin{Config: Config{NumberParts: true}}.texts(text)
+	assert.Len(t, chunks, 3)
+	assert.True(t, strings.HasSuffix(chunks[0], " (1/3)"))
+	assert.True(t, strings.HasSuffix(chunks[2], " (3/3)"))
+	for _, chunk := range chunks {
+		assert.True(t, utf8.RuneCountInString(chunk) <= maxTextLength)
+	}
+
+	// short messages are not numbered
+	assert.Equal(t, []string{"hi"}, Plugin{Config: Config{NumberParts: true}}.texts("hi"))
+
+	chunks = Plugin{Config: Config{LongMessage: LongMessageTruncate}}.texts(text)
+	assert.Len(t, chunks, 1)
+	assert.Equal(t, maxTextLength, utf8.RuneCountInString(chunks[0]))
+	assert.True(t, strings.HasSuffix(chunks[0], "…"))
+
+	assert.EqualError(t, checkLongMessage("drop"), "unknown long message mode: drop")
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+type (
+	// Mute silences the notifications of a repository for a recipient.
+	Mute struct {
+		ID    int64     `json:"id"`
+		Repo  string    `json:"repo"`
+		Until time.Time `json:"until"`
+	}
+
+	// Subscription notifies a recipient of the builds of a repository,
+	// on every branch when Branch is empty.
+	Subscription struct {
+		ID     int64  `json:"id"`
+		Repo   string `json:"repo"`
+		Branch string `json:"branch,omitempty"`
+	}
+
+	// Store keeps the chat state of the webhook server in a json file.
+	Store struct {
+		sync.Mutex
+		path string
+		data storeData
+	}
+
+	storeData struct {
+		Mutes         []Mute         `json:"mutes,omitempty"`
+		Subscriptions []Subscription `json:"subscriptions,omitempty"`
+	}
+)
+
+// OpenStore loads the store file, a missing file is an empty store.
+func OpenStore(path string) (*Store, error) {
+	s := &Store{path: path}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &s.data); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Store opens the store of the store setting, nil when not configured.
+func (p Plugin) Store() (*Store, error) {
+	if p.Config.StorePath == "" {
+		return nil, nil
+	}
+
+	return OpenStore(p.Config.StorePath)
+}
+
+// Mute silences repo for the recipient until the given time.
+func (s *Store) Mute(id int64, repo string, until time.Time) error {
+	s.Lock()
+	defer s.Unlock()
+
+	mutes := s.data.Mutes[:0]
+	for _, mute := range s.data.Mutes {
+		if mute.ID != id || mute.Repo != repo {
+			mutes = append(mutes, mute)
+		}
+	}
+	s.data.Mutes = append(mutes, Mute{ID: id, Repo: repo, Until: until})
+
+	return s.save()
+}
+
+// Muted reports whether repo is muted for the recipient.
+func (s *Store) Muted(id int64, repo string, now time.Time) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	for _, mute := range s.data.Mutes {
+		if mute.ID == id && mute.Repo == repo && now.Before(mute.Until) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Subscribe subscribes the recipient to repo, it returns false when the
+// subscription already exists.
+func (s *Store) Subscribe(id int64, repo, branch string) (bool, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	for _, sub := range s.data.Subscriptions {
+		if sub.ID == id && sub.Repo == repo && sub.Branch == branch {
+			return false, nil
+		}
+	}
+	s.data.Subscriptions = append(s.data.Subscriptions, Subscription{ID: id, Repo: repo, Branch: branch})
+
+	return true, s.save()
+}
+
+// Unsubscribe removes the subscriptions of the recipient to repo, of
+// every branch when branch is empty, and returns how many were removed.
+func (s *Store) Unsubscribe(id int64, repo, branch string) (int, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	subs := s.data.Subscriptions[:0]
+	for _, sub := range s.data.Subscriptions {
+		if sub.ID == id && sub.Repo == repo && (branch == "" || sub.Branch == branch) {
+			continue
+		}
+		subs = append(subs, sub)
+	}
+
+	removed := len(s.data.Subscriptions) - len(subs)
+	s.data.Subscriptions = subs
+	if removed == 0 {
+		return 0, nil
+	}
+
+	return removed, s.save()
+}
+
+// Subscriptions returns the subscriptions of the recipient.
+func (s *Store) Subscriptions(id int64) []Subscription {
+	s.Lock()
+	defer s.Unlock()
+
+	var subs []Subscription
+	for _, sub := range s.data.Subscriptions {
+		if sub.ID == id {
+			subs = append(subs, sub)
+		}
+	}
+
+	return subs
+}
+
+// Subscribers returns the recipients subscribed to builds of repo on branch.
+func (s *Store) Subscribers(repo, branch string) []int64 {
+	s.Lock()
+	defer s.Unlock()
+
+	var ids []int64
+	for _, sub := range s.data.Subscriptions {
+		if sub.Repo != repo {
+			continue
+		}
+		if sub.Branch != "" {
+			if ok, _ := path.Match(sub.Branch, branch); !ok {
+				continue
+			}
+		}
+		ids = append(ids, sub.ID)
+	}
+
+	return ids
+}
+
+// save writes the store through a temporary file so readers never see
+// a partial file.
+func (s *Store) save() error {
+	data, err := json.MarshalIndent(s.data, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), ".store")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// unmuted drops the recipients that muted repo.
+func (s *Store) unmuted(ids []int64, repo string, now time.Time) []int64 {
+	var out []int64
+	for _, id := range ids {
+		if s.Muted(id, repo, now) {
+			log.Printf("recipient %d muted %s\n", id, repo)
+			continue
+		}
+		out = append(out, id)
+	}
+
+	return out
+}
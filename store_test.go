@@ -0,0 +1,36 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStoreMute(t *testing.T) {
+	dir, err := ioutil.TempDir("", "drone-facebook")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "store.json")
+	store, err := OpenStore(path)
+	assert.NoError(t, err)
+
+	now := time.Now()
+	assert.NoError(t, store.Mute(1, "appleboy/go-hello", now.Add(time.Hour)))
+	assert.NoError(t, store.Mute(1, "appleboy/go-hello", now.Add(2*time.Hour)))
+
+	// reload from disk
+	store, err = OpenStore(path)
+	assert.NoError(t, err)
+	assert.Len(t, store.data.Mutes, 1)
+	assert.True(t, store.Muted(1, "appleboy/go-hello", now.Add(90*time.Minute)))
+	assert.False(t, store.Muted(1, "appleboy/go-hello", now.Add(3*time.Hour)))
+	assert.False(t, store.Muted(2, "appleboy/go-hello", now))
+	assert.False(t, store.Muted(1, "appleboy/drone-facebook", now))
+
+	assert.Equal(t, []int64{2, 3}, store.unmuted([]int64{1, 2, 3}, "appleboy/go-hello", now))
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// subscriptionCommands are the chat commands managing subscriptions.
+func (p Plugin) subscriptionCommands() []Command {
+	return []Command{
+		{
+			Name:      "subscribe",
+			Args:      "owner/repo [branch]",
+			Help:      "get notified of the builds of a repository, optionally of a branch",
+			MinArgs:   1,
+			MaxArgs:   2,
+			Authorize: p.subscriber,
+			Run:       p.subscribe,
+		},
+		{
+			Name:    "unsubscribe",
+			Args:    "owner/repo [branch]",
+			Help:    "stop the notifications of a repository, of every branch unless one is given",
+			MinArgs: 1,
+			MaxArgs: 2,
+			Run:     unsubscribe,
+		},
+		{
+			Name: "list",
+			Help: "list your subscriptions",
+			Run:  listSubscriptions,
+		},
+	}
+}
+
+const noStore = "Subscriptions are not available, the webhook server has no store configured."
+
+// subscriptionArgs returns the repository and optional branch arguments.
+func subscriptionArgs(ctx *Context) (string, string, bool) {
+	if !strings.Contains(ctx.Args[0], "/") {
+		return "", "", false
+	}
+
+	if len(ctx.Args) == 2 {
+		return ctx.Args[0], ctx.Args[1], true
+	}
+
+	return ctx.Args[0], "", true
+}
+
+// subscriber reports whether the user may subscribe, every user when no
+// subscribe users are configured. The plugin step checks the subscribers
+// again with its own settings, so the admins aren't implied.
+func (p Plugin) subscriber(user int64) bool {
+	users := trimElement(p.Config.SubscribeUsers)
+	if len(users) == 0 {
+		return true
+	}
+
+	id := strconv.FormatInt(user, 10)
+	for _, allowed := range users {
+		if allowed == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// subscribable reports whether the user may get the builds of the
+// repository, it has to match one of the subscribe repos patterns.
+func (p Plugin) subscribable(user int64, repo string) bool {
+	if !p.subscriber(user) {
+		return false
+	}
+
+	for _, pattern := range trimElement(p.Config.SubscribeRepos) {
+		if ok, _ := path.Match(pattern, repo); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p Plugin) subscribe(ctx *Context) string {
+	if ctx.Store == nil {
+		return noStore
+	}
+
+	repo, branch, ok := subscriptionArgs(ctx)
+	if !ok {
+		return ctx.Usage()
+	}
+
+	if !p.subscribable(ctx.User, repo) {
+		return fmt.Sprintf("You are not allowed to subscribe to %s.", repo)
+	}
+
+	added, err := ctx.Store.Subscribe(ctx.User, repo, branch)
+	if err != nil {
+		log.Println("error to save the subscription:", err)
+		return "Something went wrong, try again later."
+	}
+	if !added {
+		return fmt.Sprintf("Already subscribed to %s.", subscriptionLabel(repo, branch))
+	}
+
+	return fmt.Sprintf("Subscribed to %s.", subscriptionLabel(repo, branch))
+}
+
+func unsubscribe(ctx *Context) string {
+	if ctx.Store == nil {
+		return noStore
+	}
+
+	repo, branch, ok := subscriptionArgs(ctx)
+	if !ok {
+		return ctx.Usage()
+	}
+
+	removed, err := ctx.Store.Unsubscribe(ctx.User, repo, branch)
+	if err != nil {
+		log.Println("error to save the subscription:", err)
+		return "Something went wrong, try again later."
+	}
+	if removed == 0 {
+		return fmt.Sprintf("Not subscribed to %s.", subscriptionLabel(repo, branch))
+	}
+
+	return fmt.Sprintf("Unsubscribed from %s.", subscriptionLabel(repo, branch))
+}
+
+func listSubscriptions(ctx *Context) string {
+	if ctx.Store == nil {
+		return noStore
+	}
+
+	subs := ctx.Store.Subscriptions(ctx.User)
+	if len(subs) == 0 {
+		return "No subscriptions, send \"subscribe owner/repo [branch]\" to add one."
+	}
+
+	lines := []string{"Subscriptions:"}
+	for _, sub := range subs {
+		lines = append(lines, "- "+subscriptionLabel(sub.Repo, sub.Branch))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func subscriptionLabel(repo, branch string) string {
+	if branch == "" {
+		return repo
+	}
+
+	return repo + " (" + branch + ")"
+}
+
+// subscribers adds the recipients subscribed to the repository and branch
+// of the build, they get the default message. Subscriptions the allow
+// lists no longer cover are skipped.
+func (p Plugin) subscribers(store *Store, ids []int64, messages map[int64][]string) ([]int64, error) {
+	subs := store.Subscribers(p.Repo.FullName, p.Commit.Branch)
+	if len(subs) == 0 {
+		return ids, nil
+	}
+
+	message, err := p.defaultMessage()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range subs {
+		if _, ok := messages[id]; ok {
+			continue
+		}
+		if !p.subscribable(id, p.Repo.FullName) {
+			log.Println("skip the subscriber not allowed to follow the repository:", id)
+			continue
+		}
+		ids = append(ids, id)
+		messages[id] = message
+	}
+
+	return ids, nil
+}
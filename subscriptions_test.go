@@ -0,0 +1,107 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubscriptionCommands(t *testing.T) {
+	dir, err := ioutil.TempDir("", "drone-facebook")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "store.json")
+	store, err := OpenStore(path)
+	assert.NoError(t, err)
+
+	plugin := Plugin{
+		Config: Config{
+			SubscribeRepos: []string{"appleboy/*"},
+		},
+	}
+	router := plugin.Router()
+	reply := func(text string) string {
+		return router.Handle(&Context{User: 1, Store: store}, text)
+	}
+
+	assert.Equal(t, "Usage: subscribe owner/repo [branch]", reply("subscribe"))
+	assert.Equal(t, "Subscribed to appleboy/go-hello.", reply("subscribe appleboy/go-hello"))
+	assert.Equal(t, "Already subscribed to appleboy/go-hello.", reply("Subscribe appleboy/go-hello"))
+	assert.Equal(t, "Subscribed to appleboy/drone-facebook (release/*).", reply("subscribe appleboy/drone-facebook release/*"))
+	assert.Equal(t, "Subscriptions:\n- appleboy/go-hello\n- appleboy/drone-facebook (release/*)", reply("list"))
+
+	// reload from disk
+	store, err = OpenStore(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1}, store.Subscribers("appleboy/drone-facebook", "release/1.0"))
+	assert.Empty(t, store.Subscribers("appleboy/drone-facebook", "master"))
+	assert.Equal(t, []int64{1}, store.Subscribers("appleboy/go-hello", "master"))
+
+	assert.Equal(t, "Unsubscribed from appleboy/go-hello.", reply("unsubscribe appleboy/go-hello"))
+	assert.Equal(t, "Not subscribed to appleboy/go-hello.", reply("unsubscribe appleboy/go-hello"))
+	assert.Equal(t, "Unsubscribed from appleboy/drone-facebook.", reply("unsubscribe appleboy/drone-facebook"))
+	assert.Equal(t, "No subscriptions, send \"subscribe owner/repo [branch]\" to add one.", reply("list"))
+
+	assert.Equal(t, "Usage: subscribe owner/repo [branch]", reply("subscribe go-hello"))
+
+	// only the allowed repositories and users
+	assert.Equal(t, "You are not allowed to subscribe to private/repo.", reply("subscribe private/repo"))
+	plugin.Config.SubscribeUsers = []string{"2"}
+	plugin.Config.Admins = []string{"1"}
+	router = plugin.Router()
+	assert.Equal(t, "You are not allowed to run subscribe.", reply("subscribe appleboy/go-hello"))
+	assert.Equal(t, "Subscribed to appleboy/go-hello.", router.Handle(&Context{User: 2, Store: store}, "subscribe appleboy/go-hello"))
+	assert.Empty(t, store.Subscriptions(1))
+
+	store = nil
+	assert.Equal(t, "Subscriptions are not available, the webhook server has no store configured.", reply("list"))
+}
+
+func TestSendSubscribers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "drone-facebook")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "store.json")
+	store, err := OpenStore(path)
+	assert.NoError(t, err)
+	_, err = store.Subscribe(2, "appleboy/go-hello", "")
+	assert.NoError(t, err)
+	_, err = store.Subscribe(1, "appleboy/go-hello", "master")
+	assert.NoError(t, err)
+	_, err = store.Subscribe(3, "appleboy/go-hello", "develop")
+	assert.NoError(t, err)
+	_, err = store.Subscribe(4, "appleboy/go-hello", "")
+	assert.NoError(t, err)
+
+	plugin := Plugin{
+		Repo:   Repo{FullName: "appleboy/go-hello"},
+		Commit: Commit{Branch: "master"},
+		Config: Config{
+			To:             []string{"1"},
+			Message:        []string{"hello"},
+			StorePath:      path,
+			SubscribeRepos: []string{"appleboy/go-hello"},
+			SubscribeUsers: []string{"1", "2"},
+		},
+	}
+	client := &fakeSender{}
+
+	assert.NoError(t, plugin.send(client, nil))
+	assert.Equal(t, map[int64][]string{
+		1: {"hello"},
+		2: {"hello"},
+	}, client.sent)
+
+	// subscriptions of repositories no longer allowed are skipped
+	plugin.Config.SubscribeRepos = []string{"appleboy/drone-*"}
+	client = &fakeSender{}
+	assert.NoError(t, plugin.send(client, nil))
+	assert.Equal(t, map[int64][]string{
+		1: {"hello"},
+	}, client.sent)
+}
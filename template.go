@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+const filePrefix = "file://"
+
+// templateValues returns the configured message templates, the ones
+// matching the build status take precedence over message and template_file.
+func (p Plugin) templateValues() []string {
+	if values := trimElement(p.statusTemplates()); len(values) > 0 {
+		return values
+	}
+
+	values := trimElement(p.Config.Message)
+	for _, name := range trimElement(p.Config.TemplateFile) {
+		values = append(values, filePrefix+name)
+	}
+
+	return values
+}
+
+// statusTemplates picks the templates for the current build status.
+func (p Plugin) statusTemplates() []string {
+	switch p.Build.Status {
+	case "success":
+		if p.isFixed() && len(trimElement(p.Config.MessageFixed)) > 0 {
+			return p.Config.MessageFixed
+		}
+		return p.Config.MessageSuccess
+	case "failure", "error":
+		return p.Config.MessageFailure
+	case "killed", "cancelled":
+		return p.Config.MessageKilled
+	}
+
+	return nil
+}
+
+// isFixed reports whether the build recovered from a broken previous build.
+func (p Plugin) isFixed() bool {
+	switch p.Build.PrevStatus {
+	case "failure", "error", "killed", "cancelled":
+		return p.Build.Status == "success"
+	}
+
+	return false
+}
+
+// loadTemplates reads file:// values, a template that can't be read
+// fails the step.
+func (p Plugin) loadTemplates(values []string) ([]string, error) {
+	var templates []string
+
+	for _, value := range values {
+		tmpl, err := p.loadTemplate(value)
+		if err != nil {
+			return nil, fmt.Errorf("can't read template %s: %v", strings.TrimPrefix(value, filePrefix), err)
+		}
+		templates = append(templates, tmpl)
+	}
+
+	return templates, nil
+}
+
+// loadTemplate returns inline templates as is and reads file:// ones.
+func (p Plugin) loadTemplate(value string) (string, error) {
+	if !strings.HasPrefix(value, filePrefix) {
+		return value, nil
+	}
+
+	path := strings.TrimPrefix(value, filePrefix)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(p.workspace(), path)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+// workspace is the directory relative template paths are resolved from,
+// the current directory when running outside drone or GitHub Actions.
+func (p Plugin) workspace() string {
+	if p.Config.Workspace != "" {
+		return p.Config.Workspace
+	}
+
+	return p.GitHub.Workspace
+}
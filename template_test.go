@@ -0,0 +1,82 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTemplateFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "drone-facebook")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, ".drone"), 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, ".drone", "success.tmpl"), []byte("build {{ build.number }}\nby {{ commit.author }}"), 0644))
+
+	plugin := Plugin{
+		Commit: Commit{
+			Author: "appleboy",
+		},
+		Build: Build{
+			Number: 101,
+		},
+		GitHub: GitHub{
+			Workspace: dir,
+		},
+		Config: Config{
+			To:           []string{"1"},
+			Message:      []string{"inline", "file://.drone/success.tmpl", "file://missing.tmpl"},
+			TemplateFile: []string{filepath.Join(dir, ".drone", "success.tmpl")},
+		},
+	}
+
+	// a template that can't be read fails the step
+	client := &fakeSender{}
+	err = plugin.send(client, nil)
+	assert.EqualError(t, err, "can't read template missing.tmpl: open "+filepath.Join(dir, "missing.tmpl")+": no such file or directory")
+	assert.Empty(t, client.sent)
+
+	plugin.Config.Message = plugin.Config.Message[:2]
+	templates, err := plugin.loadTemplates(plugin.templateValues())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"inline",
+		"build {{ build.number }}\nby {{ commit.author }}",
+		"build {{ build.number }}\nby {{ commit.author }}",
+	}, templates)
+
+	assert.NoError(t, plugin.send(client, nil))
+	assert.Equal(t, []string{"inline", "build 101\nby appleboy", "build 101\nby appleboy"}, client.sent[1])
+}
+
+func TestStatusTemplates(t *testing.T) {
+	plugin := Plugin{
+		Config: Config{
+			Message:        []string{"message"},
+			MessageSuccess: []string{"success"},
+			MessageFailure: []string{"failure"},
+			MessageFixed:   []string{"fixed"},
+		},
+	}
+
+	plugin.Build.Status = "success"
+	assert.Equal(t, []string{"success"}, plugin.templateValues())
+
+	plugin.Build.PrevStatus = "failure"
+	assert.Equal(t, []string{"fixed"}, plugin.templateValues())
+
+	plugin.Build.Status = "error"
+	assert.Equal(t, []string{"failure"}, plugin.templateValues())
+
+	// no killed template, fall back to message
+	plugin.Build.Status = "killed"
+	assert.Equal(t, []string{"message"}, plugin.templateValues())
+
+	// fall back to the default message
+	plugin.Config.Message = nil
+	assert.Empty(t, plugin.templateValues())
+}
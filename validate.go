@@ -0,0 +1,103 @@
+package main
+
+import (
+	"log"
+	"net/mail"
+	"strconv"
+	"strings"
+)
+
+// ToError lists the malformed entries of the to setting.
+type ToError struct {
+	Invalid   []string
+	Duplicate []string
+	BadEmail  []string
+}
+
+func (e ToError) Error() string {
+	var problems []string
+	if len(e.Invalid) > 0 {
+		problems = append(problems, "invalid entries: "+strings.Join(e.Invalid, ", "))
+	}
+	if len(e.Duplicate) > 0 {
+		problems = append(problems, "duplicate ids: "+strings.Join(e.Duplicate, ", "))
+	}
+	if len(e.BadEmail) > 0 {
+		problems = append(problems, "bad emails: "+strings.Join(e.BadEmail, ", "))
+	}
+
+	return "malformed to setting: " + strings.Join(problems, "; ")
+}
+
+// validateTo checks the id and id:email entries of to. Names and groups
+// are left to the recipients file when there is one.
+func validateTo(to []string, dir *Directory) error {
+	var e ToError
+	seen := make(map[int64]bool)
+
+	for _, value := range trimElement(to) {
+		idArray := trimElement(strings.Split(value, ":"))
+		if len(idArray) == 0 {
+			e.Invalid = append(e.Invalid, value)
+			continue
+		}
+
+		id, err := strconv.ParseInt(idArray[0], 10, 64)
+		if err != nil {
+			if dir == nil || len(idArray) > 2 {
+				e.Invalid = append(e.Invalid, value)
+			} else if len(idArray) == 2 && !validEmail(idArray[1]) {
+				e.BadEmail = append(e.BadEmail, idArray[1])
+			}
+			continue
+		}
+
+		if len(idArray) > 2 {
+			e.Invalid = append(e.Invalid, value)
+			continue
+		}
+
+		if seen[id] {
+			e.Duplicate = append(e.Duplicate, idArray[0])
+		}
+		seen[id] = true
+
+		if len(idArray) == 2 && !validEmail(idArray[1]) {
+			e.BadEmail = append(e.BadEmail, idArray[1])
+		}
+	}
+
+	if len(e.Invalid) == 0 && len(e.Duplicate) == 0 && len(e.BadEmail) == 0 {
+		return nil
+	}
+
+	return e
+}
+
+func validEmail(email string) bool {
+	addr, err := mail.ParseAddress(email)
+	return err == nil && addr.Address == email
+}
+
+// checkTo fails on malformed to entries in strict mode and only logs
+// them otherwise.
+func (p Plugin) checkTo(to []string, dir *Directory) error {
+	err := validateTo(to, dir)
+	if err == nil {
+		return nil
+	}
+
+	if p.Config.StrictTo {
+		return err
+	}
+
+	e := err.(ToError)
+	log.Printf("level=warning msg=%q invalid=%q duplicate=%q bad_email=%q\n",
+		"malformed to setting",
+		strings.Join(e.Invalid, ","),
+		strings.Join(e.Duplicate, ","),
+		strings.Join(e.BadEmail, ","),
+	)
+
+	return nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateTo(t *testing.T) {
+	assert.NoError(t, validateTo([]string{"1", "2:2@gmail.com", " "}, nil))
+
+	err := validateTo([]string{"1", "l23", "1:1@gmail.com", "2:not-an-email", "3:a@b.c:x", "alice"}, nil)
+	assert.Equal(t, ToError{
+		Invalid:   []string{"l23", "3:a@b.c:x", "alice"},
+		Duplicate: []string{"1"},
+		BadEmail:  []string{"not-an-email"},
+	}, err)
+	assert.EqualError(t, err, "malformed to setting: invalid entries: l23, 3:a@b.c:x, alice; duplicate ids: 1; bad emails: not-an-email")
+
+	// names are resolved by the recipients file
+	assert.NoError(t, validateTo([]string{"1", "alice", "@oncall"}, &Directory{}))
+	assert.Equal(t, ToError{BadEmail: []string{"not-an-email"}}, validateTo([]string{"alice:alice@example.com", "bob:not-an-email"}, &Directory{}))
+}
+
+func TestStrictTo(t *testing.T) {
+	plugin := Plugin{
+		Config: Config{
+			To: []string{"1", "l23", "2", "1"},
+		},
+	}
+
+	// duplicates are warned about and notified once
+	ids, _, err := plugin.plan()
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1, 2}, ids)
+
+	plugin.Config.StrictTo = true
+	_, _, err = plugin.plan()
+	assert.EqualError(t, err, "malformed to setting: invalid entries: l23; duplicate ids: 1")
+}